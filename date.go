@@ -1,21 +1,39 @@
 package arcgis
 
 import (
+	"bytes"
 	"encoding/json"
 	"time"
 )
 
-// Arcgis returns unix timestamp in milliseconds
+// Arcgis returns unix timestamp in milliseconds. A nil Time is a null date.
 type Date struct {
 	*time.Time
 }
 
+func NewDate(t time.Time) Date {
+	return Date{&t}
+}
+
 func NewDateFromUnixMillis(unix int64) Date {
 	t := time.Unix(0, unix*int64(time.Millisecond))
 	return Date{&t}
 }
 
+// Written as a unix timestamp in milliseconds, or null if Time is nil
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.Time == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.Time.UnixMilli())
+}
+
 func (d *Date) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		d.Time = nil
+		return nil
+	}
+
 	var timestamp int64
 	if err := json.Unmarshal(b, &timestamp); err != nil {
 		return err
@@ -0,0 +1,73 @@
+package arcgis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		Name string
+		Date Date
+		JSON string
+	}{
+		{Name: "Null", Date: Date{}, JSON: "null"},
+		{Name: "Unix epoch", Date: NewDateFromUnixMillis(0), JSON: "0"},
+		{Name: "Milliseconds", Date: NewDateFromUnixMillis(1199145600123), JSON: "1199145600123"},
+		{Name: "Before unix epoch", Date: NewDate(time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)), JSON: "-1000"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			b, err := json.Marshal(test.Date)
+			if err != nil {
+				t.Fatalf("failed to marshal date: %v", err)
+			}
+			if string(b) != test.JSON {
+				t.Errorf("expected '%s', got: '%s'", test.JSON, b)
+			}
+
+			var d Date
+			if err := json.Unmarshal(b, &d); err != nil {
+				t.Fatalf("failed to unmarshal date: %v", err)
+			}
+			switch {
+			case test.Date.Time == nil:
+				if d.Time != nil {
+					t.Errorf("expected null date, got: %v", d.Time)
+				}
+			case d.Time == nil:
+				t.Errorf("expected %v, got null date", test.Date.Time)
+			case !d.Time.Equal(*test.Date.Time):
+				t.Errorf("expected %v, got: %v", test.Date.Time, d.Time)
+			}
+		})
+	}
+
+	t.Run("In attributes", func(t *testing.T) {
+		type Attributes struct {
+			Created   Date  `json:"created"`
+			Inspected *Date `json:"inspected"`
+		}
+
+		b, err := json.Marshal(Attributes{Created: NewDateFromUnixMillis(1000)})
+		if err != nil {
+			t.Fatalf("failed to marshal attributes: %v", err)
+		}
+		if expected := `{"created":1000,"inspected":null}`; string(b) != expected {
+			t.Errorf("expected '%s', got: '%s'", expected, b)
+		}
+
+		var attributes Attributes
+		if err := json.Unmarshal([]byte(`{"created":null,"inspected":2000}`), &attributes); err != nil {
+			t.Fatalf("failed to unmarshal attributes: %v", err)
+		}
+		if attributes.Created.Time != nil {
+			t.Errorf("expected null created date, got: %v", attributes.Created.Time)
+		}
+		if attributes.Inspected == nil || attributes.Inspected.UnixMilli() != 2000 {
+			t.Errorf("expected inspected date 2000, got: %v", attributes.Inspected)
+		}
+	})
+}
@@ -90,6 +90,10 @@ func main() {
 		log.Fatalf("failed to apply edits: %v", err)
 	}
 
+	if err := featureserver.ApplyEditsError(res); err != nil {
+		log.Fatalf("some edits were not applied: %v", err)
+	}
+
 	j, _ := json.MarshalIndent(res, "", "  ")
 
 	log.Printf("Success: %s", j)
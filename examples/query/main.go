@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 
@@ -26,8 +25,21 @@ func main() {
 	// Expect FeatureLayerInfo
 	flInfo := lInfo.(featureserver.FeatureLayerInfo)
 
+	type FeatureAttributes struct {
+		ObjectID       int32        `json:"objectid"`
+		Rotation       *int16       `json:"rotation"`
+		Description    *string      `json:"description"`
+		EventDate      *arcgis.Date `json:"eventdate"`
+		EventType      *int32       `json:"eventtype"`
+		CreatedUser    *string      `json:"created_user"`
+		CreatedDate    *arcgis.Date `json:"created_date"`
+		LastEditedUser *string      `json:"last_edited_user"`
+		LastEditedDate *arcgis.Date `json:"last_edited_date"`
+	}
+
 	log.Printf("Querying layer name: '%s'", flInfo.Name)
-	res, err := fsc.Layer(flInfo.ID).Query(context.Background(), featureserver.QueryVariables{
+	// Validate attributes struct against layer info and decode the features in to it
+	features, err := featureserver.QueryTyped[FeatureAttributes, featureserver.GeometryPoint](context.Background(), fsc.Layer(flInfo.ID), featureserver.QueryVariables{
 		Where: "1=1",
 		OutFields: []string{
 			"objectid",
@@ -41,33 +53,13 @@ func main() {
 			"last_edited_date",
 		},
 		ReturnGeometry: false,
-	})
+	}, featureserver.WithValidation(flInfo))
 	if err != nil {
 		log.Fatalf("failed to query layer: %v", err)
 	}
 
-	type FeatureAttributes struct {
-		ObjectID       int32        `json:"objectid"`
-		Rotation       *int16       `json:"rotation"`
-		Description    *string      `json:"description"`
-		EventDate      *arcgis.Date `json:"eventdate"`
-		EventType      *int32       `json:"eventtype"`
-		CreatedUser    *string      `json:"created_user"`
-		CreatedDate    *arcgis.Date `json:"created_date"`
-		LastEditedUser *string      `json:"last_edited_user"`
-		LastEditedDate *arcgis.Date `json:"last_edited_date"`
-	}
-
-	// Validate attributes struct against layer info
-	if err := featureserver.ValidateFeature(FeatureAttributes{}, flInfo); err != nil {
-		log.Fatalf("failed to validate attributes: %v", err)
-	}
-
-	for _, f := range res.Features {
-		var attributes FeatureAttributes
-		if err := json.Unmarshal(f.Attributes, &attributes); err != nil {
-			log.Fatalf("failed to unmarshal attributes: %v", err)
-		}
+	for _, f := range features {
+		attributes := f.Attributes
 
 		fmt.Printf(`
 ObjectID: %d
@@ -0,0 +1,302 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	defaultBatchMaxEdits    = 1000
+	defaultBatchConcurrency = 1
+)
+
+type BatchOptions struct {
+	// Maximum number of adds, updates and deletes sent in a batch. The default is 1000.
+	MaxEdits int
+	// Maximum size in bytes of the marshalled edits of a batch, the layer
+	// objects and separators included. The other parameters and the multipart
+	// encoding of the request aren't counted. An edit larger than this is sent
+	// in a batch of its own. Not limited if zero.
+	MaxBytes int
+	// Number of batches applied at the same time. The default is 1, which
+	// applies the batches one after another.
+	Concurrency int
+}
+
+// Returned by ApplyEditsBatched when a batch couldn't be applied. The results
+// of the batches that were applied are returned alongside it. The results
+// don't line up with the edits that weren't applied, those are in Failed and
+// Unsent so they can be retried.
+type ErrBatchFailed struct {
+	// Index of the first batch that failed
+	Batch int
+	// Number of batches the edits were split in to
+	Batches int
+	// Indexes of the batches that were applied
+	Applied []int
+	// The edits of every batch that failed, in batch order
+	Failed []ApplyEditsVariables
+	// The edits of the batches that weren't sent after a batch failed, in
+	// batch order
+	Unsent []ApplyEditsVariables
+	// The errors of every batch that failed
+	Err error
+}
+
+func (err ErrBatchFailed) Error() string {
+	return fmt.Sprintf("batch %d of %d failed, applied batches: %v: %v", err.Batch, err.Batches, err.Applied, err.Err)
+}
+
+func (err ErrBatchFailed) Unwrap() error {
+	return err.Err
+}
+
+// Like ApplyEdits but splits the edits in to batches by number of edits and
+// size, so large edit sets aren't rejected by the server. The results of the
+// batches are merged per layer in the order of the edits.
+//
+// Each batch is a separate request so RollbackOnFailure only applies within a
+// batch. Once a batch fails no more batches are sent, batches already being
+// applied are waited for, and ErrBatchFailed is returned with the results of
+// the batches that were applied. With a concurrency above 1 batches after the
+// failed batch may have been applied, so the results only line up with the
+// edits when no batch failed.
+func (l *Layer) ApplyEditsBatched(ctx context.Context, variables ApplyEditsVariables, options BatchOptions) (ApplyEditsResults, error) {
+	maxEdits := options.MaxEdits
+	if maxEdits <= 0 {
+		maxEdits = defaultBatchMaxEdits
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	batches, err := splitEdits(variables, maxEdits, options.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	batchResults := make([]ApplyEditsResults, len(batches))
+	batchErrs := make([]error, len(batches))
+	sent := make([]bool, len(batches))
+
+	var mu sync.Mutex
+	failed := false
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i, batch := range batches {
+		slots <- struct{}{}
+
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
+		}
+
+		sent[i] = true
+		wg.Add(1)
+		go func(i int, batch ApplyEditsVariables) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results, err := l.ApplyEdits(ctx, batch)
+			batchResults[i], batchErrs[i] = results, err
+			if err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, batch)
+	}
+
+	wg.Wait()
+
+	var results ApplyEditsResults
+	var applied []int
+	var failedBatches, unsent []ApplyEditsVariables
+	var errs []error
+	firstFailed := -1
+
+	for i, batch := range batches {
+		if !sent[i] {
+			unsent = append(unsent, batch)
+			continue
+		}
+		if batchErrs[i] != nil {
+			if firstFailed < 0 {
+				firstFailed = i
+			}
+			failedBatches = append(failedBatches, batch)
+			errs = append(errs, fmt.Errorf("batch %d: %w", i, batchErrs[i]))
+			continue
+		}
+		applied = append(applied, i)
+		results = mergeEditResults(results, batchResults[i])
+	}
+
+	if len(errs) > 0 {
+		return results, ErrBatchFailed{
+			Batch:   firstFailed,
+			Batches: len(batches),
+			Applied: applied,
+			Failed:  failedBatches,
+			Unsent:  unsent,
+			Err:     errors.Join(errs...),
+		}
+	}
+
+	return results, nil
+}
+
+type batchOperation struct {
+	// Index of the edit the operation belongs to
+	edit int
+	// One of "adds", "updates" or "deletes"
+	kind      string
+	operation interface{}
+}
+
+// Splits the edits in to batches of at most maxEdits operations and, if
+// maxBytes is greater than zero, at most maxBytes of marshalled edits. The
+// operations keep their order and every batch has the options of the
+// variables.
+func splitEdits(variables ApplyEditsVariables, maxEdits int, maxBytes int) ([]ApplyEditsVariables, error) {
+	var batches []ApplyEditsVariables
+	var operations []batchOperation
+	size := 0
+
+	// Number of bytes an operation adds to the marshalled edits of the batch,
+	// on top of the operation itself
+	overhead := func(edit int, kind string) int {
+		if len(operations) == 0 {
+			// The brackets of the edits and a new layer object
+			return 2 + len(fmt.Sprintf(`{"id":%d,"%s":[]}`, variables.Edits[edit].LayerID, kind))
+		}
+		last := operations[len(operations)-1]
+		switch {
+		case last.edit != edit:
+			// A comma and a new layer object
+			return 1 + len(fmt.Sprintf(`{"id":%d,"%s":[]}`, variables.Edits[edit].LayerID, kind))
+		case last.kind != kind:
+			// A new array in the layer object
+			return len(fmt.Sprintf(`,"%s":[]`, kind))
+		default:
+			// A comma in the array
+			return 1
+		}
+	}
+
+	flush := func() {
+		if len(operations) == 0 {
+			return
+		}
+
+		batch := variables
+		batch.Edits = nil
+
+		lastEdit := -1
+		for _, op := range operations {
+			if op.edit != lastEdit {
+				batch.Edits = append(batch.Edits, Edit{LayerID: variables.Edits[op.edit].LayerID})
+				lastEdit = op.edit
+			}
+			edit := &batch.Edits[len(batch.Edits)-1]
+			switch op.kind {
+			case "adds":
+				edit.Adds = append(edit.Adds, op.operation)
+			case "updates":
+				edit.Updates = append(edit.Updates, op.operation)
+			case "deletes":
+				edit.Deletes = append(edit.Deletes, op.operation)
+			}
+		}
+
+		batches = append(batches, batch)
+		operations = nil
+		size = 0
+	}
+
+	add := func(edit int, kind string, i int, operation interface{}) error {
+		operationJSON, err := json.Marshal(operation)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s %d of layer %d: %w", kind, i, variables.Edits[edit].LayerID, err)
+		}
+		if len(operations) >= maxEdits || (maxBytes > 0 && len(operations) > 0 && size+overhead(edit, kind)+len(operationJSON) > maxBytes) {
+			flush()
+		}
+		size += overhead(edit, kind) + len(operationJSON)
+		operations = append(operations, batchOperation{edit: edit, kind: kind, operation: operation})
+		return nil
+	}
+
+	for e, edit := range variables.Edits {
+		for i, operation := range edit.Adds {
+			if err := add(e, "adds", i, operation); err != nil {
+				return nil, err
+			}
+		}
+		for i, operation := range edit.Updates {
+			if err := add(e, "updates", i, operation); err != nil {
+				return nil, err
+			}
+		}
+		for i, operation := range edit.Deletes {
+			if err := add(e, "deletes", i, operation); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	flush()
+
+	return batches, nil
+}
+
+// Appends the results of a batch to the results of the same layer, or as a new
+// layer if there are none yet
+func mergeEditResults(merged ApplyEditsResults, results ApplyEditsResults) ApplyEditsResults {
+	for _, r := range results {
+		i := -1
+		for j := range merged {
+			if merged[j].LayerID == r.LayerID {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			merged = append(merged, r)
+			continue
+		}
+
+		m := &merged[i]
+		m.AddResults = append(m.AddResults, r.AddResults...)
+		m.UpdateResults = append(m.UpdateResults, r.UpdateResults...)
+		m.DeleteResults = append(m.DeleteResults, r.DeleteResults...)
+
+		// The moment of the last batch applied to the layer
+		if r.EditMoment.Time != nil {
+			m.EditMoment = r.EditMoment
+		}
+
+		if r.EditedFeatures != nil {
+			if m.EditedFeatures == nil {
+				editedFeatures := *r.EditedFeatures
+				m.EditedFeatures = &editedFeatures
+			} else {
+				m.EditedFeatures.Adds = append(m.EditedFeatures.Adds, r.EditedFeatures.Adds...)
+				m.EditedFeatures.Updates = append(m.EditedFeatures.Updates, r.EditedFeatures.Updates...)
+				m.EditedFeatures.Deletes = append(m.EditedFeatures.Deletes, r.EditedFeatures.Deletes...)
+			}
+		}
+	}
+
+	return merged
+}
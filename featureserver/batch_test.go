@@ -0,0 +1,249 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Serves applyEdits by returning the object ids that were deleted as the
+// results, so the merged results show the order the deletes were applied in
+func newBatchTestServer(t *testing.T, fail func(deletes []int) bool) (*httptest.Server, *[][]int) {
+	var mu sync.Mutex
+	var batches [][]int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+
+		var edits []struct {
+			LayerID uint8 `json:"id"`
+			Deletes []int `json:"deletes"`
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("edits")), &edits); err != nil {
+			t.Errorf("failed to unmarshal edits: %v", err)
+			return
+		}
+
+		var deletes []int
+		for _, edit := range edits {
+			deletes = append(deletes, edit.Deletes...)
+		}
+
+		mu.Lock()
+		batches = append(batches, deletes)
+		sent := len(batches)
+		mu.Unlock()
+
+		if fail != nil && fail(deletes) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// Later batches finish first so the results arrive out of order
+		time.Sleep(time.Duration(10-sent) * time.Millisecond)
+
+		var results ApplyEditsResults
+		for _, edit := range edits {
+			layerResults := LayerEditResults{LayerID: edit.LayerID}
+			for _, id := range edit.Deletes {
+				layerResults.DeleteResults = append(layerResults.DeleteResults, EditResult{ObjectID: id, Success: true})
+			}
+			results = append(results, layerResults)
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+
+	return srv, &batches
+}
+
+func TestLayerApplyEditsBatched(t *testing.T) {
+	deletes := func(ids ...int) []DeleteOperation {
+		operations := make([]DeleteOperation, len(ids))
+		for i, id := range ids {
+			operations[i] = id
+		}
+		return operations
+	}
+
+	variables := ApplyEditsVariables{
+		Edits: []Edit{
+			{LayerID: 0, Deletes: deletes(1, 2, 3, 4, 5)},
+			{LayerID: 1, Deletes: deletes(6, 7, 8)},
+		},
+	}
+
+	deletedIDs := func(results ApplyEditsResults) map[uint8][]int {
+		ids := make(map[uint8][]int)
+		for _, r := range results {
+			for _, d := range r.DeleteResults {
+				ids[r.LayerID] = append(ids[r.LayerID], d.ObjectID)
+			}
+		}
+		return ids
+	}
+
+	t.Run("Splits by number of edits", func(t *testing.T) {
+		srv, batches := newBatchTestServer(t, nil)
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		results, err := fsc.Layer(0).ApplyEditsBatched(context.Background(), variables, BatchOptions{MaxEdits: 3})
+		if err != nil {
+			t.Fatalf("failed to apply edits: %v", err)
+		}
+
+		expectedBatches := [][]int{{1, 2, 3}, {4, 5, 6}, {7, 8}}
+		if len(*batches) != len(expectedBatches) {
+			t.Fatalf("expected %d batches, got: %v", len(expectedBatches), *batches)
+		}
+		for i, batch := range expectedBatches {
+			if fmt.Sprint((*batches)[i]) != fmt.Sprint(batch) {
+				t.Errorf("expected batch %d to be %v, got: %v", i, batch, (*batches)[i])
+			}
+		}
+
+		ids := deletedIDs(results)
+		if fmt.Sprint(ids[0]) != fmt.Sprint([]int{1, 2, 3, 4, 5}) || fmt.Sprint(ids[1]) != fmt.Sprint([]int{6, 7, 8}) {
+			t.Errorf("unexpected merged results: %v", ids)
+		}
+	})
+
+	t.Run("Splits by size", func(t *testing.T) {
+		srv, batches := newBatchTestServer(t, nil)
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		// [{"id":0,"deletes":[1,2,3]}] is 28 bytes, adding a delete or a layer
+		// to it goes over the limit
+		if _, err := fsc.Layer(0).ApplyEditsBatched(context.Background(), variables, BatchOptions{MaxBytes: 28}); err != nil {
+			t.Fatalf("failed to apply edits: %v", err)
+		}
+		if expected := "[[1 2 3] [4 5] [6 7 8]]"; fmt.Sprint(*batches) != expected {
+			t.Errorf("expected batches %s, got: %v", expected, *batches)
+		}
+	})
+
+	t.Run("Merges concurrent batches in order", func(t *testing.T) {
+		srv, _ := newBatchTestServer(t, nil)
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		results, err := fsc.Layer(0).ApplyEditsBatched(context.Background(), variables, BatchOptions{MaxEdits: 1, Concurrency: 4})
+		if err != nil {
+			t.Fatalf("failed to apply edits: %v", err)
+		}
+
+		ids := deletedIDs(results)
+		if fmt.Sprint(ids[0]) != fmt.Sprint([]int{1, 2, 3, 4, 5}) || fmt.Sprint(ids[1]) != fmt.Sprint([]int{6, 7, 8}) {
+			t.Errorf("unexpected merged results: %v", ids)
+		}
+	})
+
+	t.Run("Stops at failed batch", func(t *testing.T) {
+		srv, batches := newBatchTestServer(t, func(deletes []int) bool {
+			return deletes[0] == 4
+		})
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		results, err := fsc.Layer(0).ApplyEditsBatched(context.Background(), variables, BatchOptions{MaxEdits: 3})
+
+		var batchErr ErrBatchFailed
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("expected ErrBatchFailed, got: %v", err)
+		}
+		if batchErr.Batch != 1 || batchErr.Batches != 3 || fmt.Sprint(batchErr.Applied) != fmt.Sprint([]int{0}) {
+			t.Errorf("unexpected batch error: %v", batchErr)
+		}
+		if len(*batches) != 2 {
+			t.Errorf("expected no batches after the failed batch to be sent, got: %v", *batches)
+		}
+		if len(batchErr.Failed) != 1 || fmt.Sprint(batchDeletes(batchErr.Failed[0])) != fmt.Sprint([]int{4, 5, 6}) {
+			t.Errorf("expected failed edits [4 5 6], got: %v", batchErr.Failed)
+		}
+		if len(batchErr.Unsent) != 1 || fmt.Sprint(batchDeletes(batchErr.Unsent[0])) != fmt.Sprint([]int{7, 8}) {
+			t.Errorf("expected unsent edits [7 8], got: %v", batchErr.Unsent)
+		}
+
+		ids := deletedIDs(results)
+		if fmt.Sprint(ids[0]) != fmt.Sprint([]int{1, 2, 3}) || len(ids[1]) != 0 {
+			t.Errorf("expected results of the applied batch, got: %v", ids)
+		}
+	})
+
+	t.Run("Reports failed and unsent edits of concurrent batches", func(t *testing.T) {
+		srv, _ := newBatchTestServer(t, func(deletes []int) bool {
+			return deletes[0] == 4
+		})
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		results, err := fsc.Layer(0).ApplyEditsBatched(context.Background(), variables, BatchOptions{MaxEdits: 3, Concurrency: 2})
+
+		var batchErr ErrBatchFailed
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("expected ErrBatchFailed, got: %v", err)
+		}
+		if batchErr.Batch != 1 || batchErr.Batches != 3 {
+			t.Errorf("unexpected batch error: %v", batchErr)
+		}
+		if len(batchErr.Failed) != 1 || fmt.Sprint(batchDeletes(batchErr.Failed[0])) != fmt.Sprint([]int{4, 5, 6}) {
+			t.Errorf("expected failed edits [4 5 6], got: %v", batchErr.Failed)
+		}
+
+		// The last batch may have been sent before the failure was known, every
+		// edit must either have a result or be reported as not applied
+		ids := deletedIDs(results)
+		applied := append(ids[0], ids[1]...)
+		var notApplied []int
+		for _, batch := range append(batchErr.Failed, batchErr.Unsent...) {
+			notApplied = append(notApplied, batchDeletes(batch)...)
+		}
+		if fmt.Sprint(ids[0]) != fmt.Sprint([]int{1, 2, 3}) {
+			t.Errorf("expected results [1 2 3] for layer 0, got: %v", ids[0])
+		}
+		if len(applied)+len(notApplied) != 8 {
+			t.Errorf("expected every edit to be applied or reported, applied: %v, not applied: %v", applied, notApplied)
+		}
+	})
+}
+
+// Returns the object ids deleted by a batch
+func batchDeletes(batch ApplyEditsVariables) []int {
+	var ids []int
+	for _, edit := range batch.Edits {
+		for _, d := range edit.Deletes {
+			ids = append(ids, d.(int))
+		}
+	}
+	return ids
+}
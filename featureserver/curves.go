@@ -0,0 +1,291 @@
+package featureserver
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// Largest angle of arc between two vertices of a densified arc
+	curveMaxAngle = math.Pi / 36
+	// Number of straight segments a bezier curve is densified in to
+	curveBezierSegments = 16
+)
+
+// Replaces the curvePaths and curveRings returned for true curves with paths
+// and rings. Each curve segment, an object such as {"c": [end, interior]}, is
+// densified in to straight segments:
+//   - "c" circular arcs through an interior point
+//   - "a" circular and elliptic arcs around a center
+//   - "b" cubic bezier curves
+//
+// Z and m values are interpolated between the start and end of the curve.
+func linearizeCurves(raw map[string]interface{}) (map[string]interface{}, error) {
+	_, hasCurvePaths := raw["curvePaths"]
+	_, hasCurveRings := raw["curveRings"]
+	if !hasCurvePaths && !hasCurveRings {
+		return raw, nil
+	}
+
+	linearized := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		linearized[k] = v
+	}
+
+	for curveKey, key := range map[string]string{"curvePaths": "paths", "curveRings": "rings"} {
+		parts, ok := raw[curveKey].([]interface{})
+		if !ok {
+			continue
+		}
+		delete(linearized, curveKey)
+
+		linearParts := make([]interface{}, len(parts))
+		for i, part := range parts {
+			segments, _ := part.([]interface{})
+			var linearPart []interface{}
+			var start []interface{}
+			for j, segment := range segments {
+				switch segment := segment.(type) {
+				case []interface{}:
+					linearPart = append(linearPart, segment)
+					start = segment
+				case map[string]interface{}:
+					if start == nil {
+						return nil, fmt.Errorf("curve %d of %s %d has no start point", j, key, i)
+					}
+					points, err := densifyCurve(start, segment)
+					if err != nil {
+						return nil, fmt.Errorf("failed to densify curve %d of %s %d: %w", j, key, i, err)
+					}
+					linearPart = append(linearPart, points...)
+					start = points[len(points)-1].([]interface{})
+				default:
+					return nil, fmt.Errorf("unexpected segment %d of %s %d: %v", j, key, i, segment)
+				}
+			}
+			linearParts[i] = linearPart
+		}
+		linearized[key] = linearParts
+	}
+
+	return linearized, nil
+}
+
+// Returns the vertices after start that approximate the curve, ending with the
+// end point of the curve
+func densifyCurve(start []interface{}, curve map[string]interface{}) ([]interface{}, error) {
+	if len(curve) != 1 {
+		return nil, fmt.Errorf("curve must have a single type but has %d", len(curve))
+	}
+
+	for curveType, v := range curve {
+		values, ok := v.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("curve '%s' has no values", curveType)
+		}
+		end, ok := values[0].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("curve '%s' has no end point", curveType)
+		}
+		x0, y0, err := curveXY(start)
+		if err != nil {
+			return nil, err
+		}
+		x1, y1, err := curveXY(end)
+		if err != nil {
+			return nil, err
+		}
+
+		switch curveType {
+		case "c":
+			if len(values) < 2 {
+				return nil, fmt.Errorf("circular arc has no interior point")
+			}
+			interior, _ := values[1].([]interface{})
+			ix, iy, err := curveXY(interior)
+			if err != nil {
+				return nil, err
+			}
+			return densifyArcThroughPoint(start, end, x0, y0, ix, iy, x1, y1), nil
+		case "a":
+			if len(values) < 4 {
+				return nil, fmt.Errorf("elliptic arc must have at least 4 values but has %d", len(values))
+			}
+			center, _ := values[1].([]interface{})
+			cx, cy, err := curveXY(center)
+			if err != nil {
+				return nil, err
+			}
+			clockwise, err := curveFlag(values[3])
+			if err != nil {
+				return nil, err
+			}
+			// Circular arcs leave out the rotation, semi-major axis and ratio
+			rotation, axis, ratio := 0.0, math.Hypot(x0-cx, y0-cy), 1.0
+			if len(values) >= 7 {
+				var ok1, ok2, ok3 bool
+				rotation, ok1 = values[4].(float64)
+				axis, ok2 = values[5].(float64)
+				ratio, ok3 = values[6].(float64)
+				if !ok1 || !ok2 || !ok3 {
+					return nil, fmt.Errorf("elliptic arc rotation, axis and ratio must be numbers")
+				}
+			}
+			return densifyEllipticArc(start, end, x0, y0, x1, y1, cx, cy, clockwise, rotation, axis, axis*ratio), nil
+		case "b":
+			if len(values) < 3 {
+				return nil, fmt.Errorf("bezier curve must have 2 control points")
+			}
+			control1, _ := values[1].([]interface{})
+			control2, _ := values[2].([]interface{})
+			c1x, c1y, err := curveXY(control1)
+			if err != nil {
+				return nil, err
+			}
+			c2x, c2y, err := curveXY(control2)
+			if err != nil {
+				return nil, err
+			}
+			points := make([]interface{}, 0, curveBezierSegments)
+			for k := 1; k < curveBezierSegments; k++ {
+				t := float64(k) / curveBezierSegments
+				a, b, c, d := (1-t)*(1-t)*(1-t), 3*(1-t)*(1-t)*t, 3*(1-t)*t*t, t*t*t
+				x := a*x0 + b*c1x + c*c2x + d*x1
+				y := a*y0 + b*c1y + c*c2y + d*y1
+				points = append(points, curveVertex(start, end, x, y, t))
+			}
+			return append(points, end), nil
+		default:
+			return nil, fmt.Errorf("unhandled curve type '%s'", curveType)
+		}
+	}
+
+	return nil, nil
+}
+
+// Densifies the circular arc from start through the interior point to end. A
+// closed arc, where start and end are the same, is a full circle with the
+// interior point opposite the start and is densified clockwise.
+func densifyArcThroughPoint(start, end []interface{}, x0, y0, ix, iy, x1, y1 float64) []interface{} {
+	var cx, cy, sweep float64
+
+	if x0 == x1 && y0 == y1 {
+		cx, cy = (x0+ix)/2, (y0+iy)/2
+		sweep = -2 * math.Pi
+	} else {
+		d := 2 * (x0*(iy-y1) + ix*(y1-y0) + x1*(y0-iy))
+		// The points are on a line, the arc is a straight segment
+		if math.Abs(d) < 1e-12*math.Max(1, math.Abs(x0)+math.Abs(y0)+math.Abs(x1)+math.Abs(y1)) {
+			return []interface{}{end}
+		}
+		s0, si, s1 := x0*x0+y0*y0, ix*ix+iy*iy, x1*x1+y1*y1
+		cx = (s0*(iy-y1) + si*(y1-y0) + s1*(y0-iy)) / d
+		cy = (s0*(x1-ix) + si*(x0-x1) + s1*(ix-x0)) / d
+
+		a0 := math.Atan2(y0-cy, x0-cx)
+		counterclockwise := normalizeAngle(math.Atan2(y1-cy, x1-cx) - a0)
+		interior := normalizeAngle(math.Atan2(iy-cy, ix-cx) - a0)
+		sweep = counterclockwise
+		if interior > counterclockwise {
+			sweep = counterclockwise - 2*math.Pi
+		}
+	}
+
+	radius := math.Hypot(x0-cx, y0-cy)
+	return densifyEllipticArc(start, end, x0, y0, x1, y1, cx, cy, sweep < 0, 0, radius, radius)
+}
+
+// Densifies the arc of the ellipse around the center with the semi-major axis
+// a rotated by rotation radians and the semi-minor axis b
+func densifyEllipticArc(start, end []interface{}, x0, y0, x1, y1, cx, cy float64, clockwise bool, rotation, a, b float64) []interface{} {
+	if a == 0 || b == 0 {
+		return []interface{}{end}
+	}
+
+	sin, cos := math.Sin(rotation), math.Cos(rotation)
+	// The parametric angle of a point on the ellipse
+	angle := func(x, y float64) float64 {
+		lx := (x-cx)*cos + (y-cy)*sin
+		ly := -(x-cx)*sin + (y-cy)*cos
+		return math.Atan2(ly/b, lx/a)
+	}
+
+	t0 := angle(x0, y0)
+	sweep := normalizeAngle(angle(x1, y1) - t0)
+	switch {
+	case sweep == 0 && clockwise:
+		// A closed arc is a full ellipse
+		sweep = -2 * math.Pi
+	case sweep == 0:
+		sweep = 2 * math.Pi
+	case clockwise:
+		sweep -= 2 * math.Pi
+	}
+
+	n := int(math.Ceil(math.Abs(sweep) / curveMaxAngle))
+	points := make([]interface{}, 0, n)
+	for k := 1; k < n; k++ {
+		f := float64(k) / float64(n)
+		t := t0 + sweep*f
+		lx, ly := a*math.Cos(t), b*math.Sin(t)
+		points = append(points, curveVertex(start, end, cx+lx*cos-ly*sin, cy+lx*sin+ly*cos, f))
+	}
+
+	return append(points, end)
+}
+
+// Returns the angle in [0, 2π)
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	// Angles just below 2π are rounding errors of 0
+	if 2*math.Pi-angle < 1e-12 {
+		return 0
+	}
+	return angle
+}
+
+// Returns a vertex at x, y with the z and m values interpolated between start
+// and end by f
+func curveVertex(start, end []interface{}, x, y float64, f float64) []interface{} {
+	vertex := make([]interface{}, len(end))
+	vertex[0], vertex[1] = x, y
+	for i := 2; i < len(end); i++ {
+		vertex[i] = end[i]
+		if i >= len(start) {
+			continue
+		}
+		from, ok1 := start[i].(float64)
+		to, ok2 := end[i].(float64)
+		if ok1 && ok2 {
+			vertex[i] = from + (to-from)*f
+		}
+	}
+	return vertex
+}
+
+func curveXY(point []interface{}) (x, y float64, err error) {
+	if len(point) < 2 {
+		return 0, 0, fmt.Errorf("curve point must have x and y values but has %d values", len(point))
+	}
+	x, ok1 := point[0].(float64)
+	y, ok2 := point[1].(float64)
+	if !ok1 || !ok2 {
+		return 0, 0, fmt.Errorf("curve point x and y values must be numbers")
+	}
+	return x, y, nil
+}
+
+// Flags of elliptic arcs are written as numbers or booleans
+func curveFlag(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	default:
+		return false, fmt.Errorf("curve flag must be a number or boolean but got %T", v)
+	}
+}
@@ -0,0 +1,49 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Returns the distinct values of a field in ascending order, paging through
+// them when there are more than the server returns at once. Strings are
+// returned as string, numbers and dates as float64 and nulls as nil.
+func (l *Layer) DistinctValues(ctx context.Context, field string) ([]interface{}, error) {
+	it := l.QueryAll(ctx, QueryVariables{
+		Where:                "1=1",
+		OutFields:            []string{field},
+		ReturnDistinctValues: true,
+		OrderByFields:        []OrderByField{{Field: field, Order: OrderAsc}},
+	})
+
+	var values []interface{}
+	for it.Next() {
+		var attributes map[string]interface{}
+		if err := json.Unmarshal(it.Feature().Attributes, &attributes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attributes: %w", err)
+		}
+
+		value, ok := attributes[field]
+		if !ok {
+			// The server may return the field name in a different case
+			for name, v := range attributes {
+				if strings.EqualFold(name, field) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("missing field '%s' in attributes", field)
+		}
+
+		values = append(values, value)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
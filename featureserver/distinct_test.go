@@ -0,0 +1,61 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestLayerDistinctValues(t *testing.T) {
+	values := []interface{}{nil, "Elm", "Main", "Oak", "Pine"}
+	const pageSize = 2
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+		expected := map[string]string{
+			"outFields":            "street",
+			"returnDistinctValues": "true",
+			"returnGeometry":       "false",
+			"orderByFields":        "street ASC",
+		}
+		for name, value := range expected {
+			if r.FormValue(name) != value {
+				t.Errorf("expected %s '%s', got: '%s'", name, value, r.FormValue(name))
+			}
+		}
+
+		offset, _ := strconv.Atoi(r.FormValue("resultOffset"))
+		var features []map[string]interface{}
+		for i := offset; i < len(values) && i < offset+pageSize; i++ {
+			features = append(features, map[string]interface{}{
+				"attributes": map[string]interface{}{"STREET": values[i]},
+			})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"features":              features,
+			"exceededTransferLimit": offset+pageSize < len(values),
+		})
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	got, err := fsc.Layer(0).DistinctValues(context.Background(), "street")
+	if err != nil {
+		t.Fatalf("failed to get distinct values: %v", err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("expected values %v, got: %v", values, got)
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/TheAschr/arcgis"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -24,19 +29,170 @@ type Edit struct {
 	Deletes []DeleteOperation `json:"deletes,omitempty"`
 }
 
+const (
+	// Only the edit results are returned, the default
+	ReturnServiceEditsOptionNone = "none"
+	// The features as they were before and after the edits are returned as well,
+	// including the features of other layers edited by composite relationships
+	ReturnServiceEditsOptionOriginalAndCurrentFeatures = "originalAndCurrentFeatures"
+)
+
 type ApplyEditsVariables struct {
 	Edits []Edit `json:"edits"`
+	// If true, no edits are applied unless all of them succeed. If nil the server default is used, which is true.
+	RollbackOnFailure *bool `json:"rollbackOnFailure,omitempty"`
+	// If true, features are identified by their global ids instead of their object ids, and deletes are global ids.
+	UseGlobalIds bool `json:"useGlobalIds,omitempty"`
+	// If true, the time the edits were applied is returned in the results.
+	ReturnEditMoment bool `json:"returnEditMoment,omitempty"`
+	// Can be one of:
+	//  - ReturnServiceEditsOptionNone
+	//  - ReturnServiceEditsOptionOriginalAndCurrentFeatures
+	ReturnServiceEditsOption string `json:"returnServiceEditsOption,omitempty"`
+	// The geodatabase version to apply the edits to. If not set, the edits are applied to the default version.
+	GdbVersion string `json:"gdbVersion,omitempty"`
+	// The id of the edit session of a branch versioned service.
+	SessionID string `json:"sessionID,omitempty"`
+}
+
+// The result of a single add, update or delete
+type EditResult struct {
+	ObjectID int    `json:"objectId"`
+	GlobalID string `json:"globalId,omitempty"`
+	Success  bool   `json:"success"`
+	// Only set when the edit failed
+	Error *ErrEditFailed `json:"error,omitempty"`
 }
 
 type LayerEditResults struct {
-	LayerID       uint8             `json:"id"`
-	AddResults    []json.RawMessage `json:"addsResults,omitempty"`
-	UpdateResults []json.RawMessage `json:"updateResults,omitempty"`
-	DeleteResults []json.RawMessage `json:"deleteResults,omitempty"`
+	LayerID       uint8        `json:"id"`
+	AddResults    []EditResult `json:"addResults,omitempty"`
+	UpdateResults []EditResult `json:"updateResults,omitempty"`
+	DeleteResults []EditResult `json:"deleteResults,omitempty"`
+	// When the edits were applied, only set when ReturnEditMoment is true
+	EditMoment arcgis.Date `json:"editMoment"`
+	// Only set when ReturnServiceEditsOption is ReturnServiceEditsOptionOriginalAndCurrentFeatures
+	EditedFeatures *EditedFeatures `json:"editedFeatures,omitempty"`
+}
+
+// A feature before and after it was updated
+type EditedFeatureUpdate struct {
+	Original Feature
+	Current  Feature
+}
+
+// The features of a layer affected by the edits
+type EditedFeatures struct {
+	SpatialReference *SpatialReference     `json:"spatialReference"`
+	Adds             []Feature             `json:"adds"`
+	Updates          []EditedFeatureUpdate `json:"updates"`
+	Deletes          []Feature             `json:"deletes"`
+}
+
+func (e *EditedFeatures) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		SpatialReference *SpatialReference `json:"spatialReference"`
+		Adds             []Feature         `json:"adds"`
+		Updates          [][]Feature       `json:"updates"`
+		Deletes          []Feature         `json:"deletes"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	// The response has no geometry type, hasZ or hasM so they're taken from
+	// each geometry
+	decode := func(f *Feature) error {
+		rawGeometry, ok := f.Geometry.(map[string]interface{})
+		if !ok {
+			f.Geometry = GeometryNone{}
+			return nil
+		}
+		hasZ, hasM := inferCoordinateDimensions(rawGeometry)
+		geometry, err := decodeGeometry(inferGeometryType(rawGeometry), hasZ, hasM, raw.SpatialReference, rawGeometry)
+		if err != nil {
+			return err
+		}
+		f.Geometry = geometry
+		return nil
+	}
+
+	e.SpatialReference = raw.SpatialReference
+	e.Adds = raw.Adds
+	e.Deletes = raw.Deletes
+	e.Updates = make([]EditedFeatureUpdate, len(raw.Updates))
+
+	for i, update := range raw.Updates {
+		if len(update) != 2 {
+			return fmt.Errorf("update %d must have an original and current feature but has %d features", i, len(update))
+		}
+		e.Updates[i] = EditedFeatureUpdate{Original: update[0], Current: update[1]}
+		if err := decode(&e.Updates[i].Original); err != nil {
+			return fmt.Errorf("failed to decode original feature of update %d: %w", i, err)
+		}
+		if err := decode(&e.Updates[i].Current); err != nil {
+			return fmt.Errorf("failed to decode current feature of update %d: %w", i, err)
+		}
+	}
+	for i := range e.Adds {
+		if err := decode(&e.Adds[i]); err != nil {
+			return fmt.Errorf("failed to decode added feature %d: %w", i, err)
+		}
+	}
+	for i := range e.Deletes {
+		if err := decode(&e.Deletes[i]); err != nil {
+			return fmt.Errorf("failed to decode deleted feature %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns an error listing every add, update and delete of the layer that
+// failed, or nil if they all succeeded. Each failure wraps its ErrEditFailed.
+func (r LayerEditResults) Err() error {
+	var errs []error
+
+	collect := func(operation string, results []EditResult) {
+		for i, result := range results {
+			if result.Success {
+				continue
+			}
+			editErr := result.Error
+			if editErr == nil {
+				editErr = &ErrEditFailed{}
+			}
+			id := ""
+			if result.ObjectID != 0 {
+				id = fmt.Sprintf(" of object id %d", result.ObjectID)
+			} else if result.GlobalID != "" {
+				id = fmt.Sprintf(" of global id %s", result.GlobalID)
+			}
+			errs = append(errs, fmt.Errorf("layer %d: %s %d%s failed: %w", r.LayerID, operation, i, id, *editErr))
+		}
+	}
+
+	collect("add", r.AddResults)
+	collect("update", r.UpdateResults)
+	collect("delete", r.DeleteResults)
+
+	return errors.Join(errs...)
 }
 
 type ApplyEditsResults = []LayerEditResults
 
+// Returns an error listing every edit that failed in each layer, or nil if
+// they all succeeded
+func ApplyEditsError(results ApplyEditsResults) error {
+	var errs []error
+	for _, r := range results {
+		if err := r.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (l *Layer) ApplyEdits(ctx context.Context, variables ApplyEditsVariables) (results ApplyEditsResults, err error) {
 	u, err := url.Parse(l.fs.url)
 	if err != nil {
@@ -62,6 +218,42 @@ func (l *Layer) ApplyEdits(ctx context.Context, variables ApplyEditsVariables) (
 		return results, fmt.Errorf("failed to write 'edits' field: %w", err)
 	}
 
+	if variables.RollbackOnFailure != nil {
+		if err := formBodyWriter.WriteField("rollbackOnFailure", fmt.Sprintf("%t", *variables.RollbackOnFailure)); err != nil {
+			return results, fmt.Errorf("failed to write 'rollbackOnFailure' field: %w", err)
+		}
+	}
+
+	if variables.UseGlobalIds {
+		if err := formBodyWriter.WriteField("useGlobalIds", "true"); err != nil {
+			return results, fmt.Errorf("failed to write 'useGlobalIds' field: %w", err)
+		}
+	}
+
+	if variables.ReturnEditMoment {
+		if err := formBodyWriter.WriteField("returnEditMoment", "true"); err != nil {
+			return results, fmt.Errorf("failed to write 'returnEditMoment' field: %w", err)
+		}
+	}
+
+	if variables.ReturnServiceEditsOption != "" {
+		if err := formBodyWriter.WriteField("returnServiceEditsOption", variables.ReturnServiceEditsOption); err != nil {
+			return results, fmt.Errorf("failed to write 'returnServiceEditsOption' field: %w", err)
+		}
+	}
+
+	if variables.GdbVersion != "" {
+		if err := formBodyWriter.WriteField("gdbVersion", variables.GdbVersion); err != nil {
+			return results, fmt.Errorf("failed to write 'gdbVersion' field: %w", err)
+		}
+	}
+
+	if variables.SessionID != "" {
+		if err := formBodyWriter.WriteField("sessionID", variables.SessionID); err != nil {
+			return results, fmt.Errorf("failed to write 'sessionID' field: %w", err)
+		}
+	}
+
 	if err := formBodyWriter.WriteField("f", "json"); err != nil {
 		return results, fmt.Errorf("failed to write 'f' field: %w", err)
 	}
@@ -115,3 +307,250 @@ func (l *Layer) ApplyEdits(ctx context.Context, variables ApplyEditsVariables) (
 
 	return results, nil
 }
+
+type AddFeaturesVariables struct {
+	// The features to add. Each is checked with ValidateFeature before any is sent.
+	Features []AddOperation
+	// The info of the layer the features are validated against. If nil it is fetched from the server.
+	Info Info
+}
+
+// Adds features to the layer and returns the result of each, in the same order
+// as the features
+func (l *Layer) AddFeatures(ctx context.Context, variables AddFeaturesVariables) ([]EditResult, error) {
+	if err := l.validateFeatures(ctx, variables.Features, variables.Info); err != nil {
+		return nil, err
+	}
+
+	featuresJSON, err := json.Marshal(variables.Features)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal 'features' field: %w", err)
+	}
+
+	results, err := l.editFeatures(ctx, "addFeatures", map[string]string{"features": string(featuresJSON)})
+	if err != nil {
+		return nil, err
+	}
+
+	return results.AddResults, nil
+}
+
+type UpdateFeaturesVariables struct {
+	// The features to update, identified by the object id in their attributes.
+	// Each is checked with ValidateFeature before any is sent.
+	Features []UpdateOperation
+	// The info of the layer the features are validated against. If nil it is fetched from the server.
+	Info Info
+}
+
+// Updates features of the layer and returns the result of each, in the same
+// order as the features
+func (l *Layer) UpdateFeatures(ctx context.Context, variables UpdateFeaturesVariables) ([]EditResult, error) {
+	if err := l.validateFeatures(ctx, variables.Features, variables.Info); err != nil {
+		return nil, err
+	}
+
+	featuresJSON, err := json.Marshal(variables.Features)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal 'features' field: %w", err)
+	}
+
+	results, err := l.editFeatures(ctx, "updateFeatures", map[string]string{"features": string(featuresJSON)})
+	if err != nil {
+		return nil, err
+	}
+
+	return results.UpdateResults, nil
+}
+
+// At least one of ObjectIDs, Where or Geometry must be set. When several are
+// set only the features matching all of them are deleted.
+type DeleteFeaturesVariables struct {
+	// The object ids of the features to delete
+	ObjectIDs []int
+	// A SQL where clause selecting the features to delete
+	Where string
+	// Deletes the features that have the spatial relationship with the geometry. Can be one of:
+	//  - GeometryPoint
+	//  - GeometryMultiPoint
+	//  - GeometryPolyline
+	//  - GeometryPolygon
+	//  - GeometryEnvelope
+	Geometry interface{}
+	// The type of geometry specified by the geometry parameter. If not set, it is derived from the geometry.
+	GeometryType string
+	// The spatial relationship to be applied to the geometry. The default is SpatialRelIntersects.
+	SpatialRel string
+	// The spatial reference of the geometry. If not set, the geometry is assumed to be in the spatial reference of the layer.
+	InSR *SpatialReference
+}
+
+// Deletes features of the layer and returns the result of each deleted feature
+func (l *Layer) DeleteFeatures(ctx context.Context, variables DeleteFeaturesVariables) ([]EditResult, error) {
+	if len(variables.ObjectIDs) == 0 && variables.Where == "" && variables.Geometry == nil {
+		return nil, fmt.Errorf("object ids, where or geometry must be set")
+	}
+
+	fields := make(map[string]string)
+
+	if len(variables.ObjectIDs) > 0 {
+		objectIDs := make([]string, len(variables.ObjectIDs))
+		for i, objectID := range variables.ObjectIDs {
+			objectIDs[i] = strconv.Itoa(objectID)
+		}
+		fields["objectIds"] = strings.Join(objectIDs, ",")
+	}
+
+	if variables.Where != "" {
+		fields["where"] = variables.Where
+	}
+
+	if variables.Geometry != nil {
+		geometryType := variables.GeometryType
+		if geometryType == GeometryTypeNone {
+			var err error
+			geometryType, err = geometryTypeOf(variables.Geometry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get 'geometryType': %w", err)
+			}
+		}
+
+		geometryJSON, err := json.Marshal(variables.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'geometry' field: %w", err)
+		}
+
+		fields["geometry"] = string(geometryJSON)
+		fields["geometryType"] = geometryType
+
+		if variables.SpatialRel != "" {
+			fields["spatialRel"] = variables.SpatialRel
+		}
+
+		if variables.InSR != nil {
+			inSRJSON, err := json.Marshal(variables.InSR)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal 'inSR' field: %w", err)
+			}
+			fields["inSR"] = string(inSRJSON)
+		}
+	}
+
+	results, err := l.editFeatures(ctx, "deleteFeatures", fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return results.DeleteResults, nil
+}
+
+// Runs ValidateFeature on every feature, fetching the layer info if it's nil
+func (l *Layer) validateFeatures(ctx context.Context, features []interface{}, info Info) error {
+	if len(features) == 0 {
+		return nil
+	}
+
+	if info == nil {
+		var err error
+		info, err = l.Info(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get layer info: %w", err)
+		}
+	}
+
+	for i, feature := range features {
+		if err := ValidateFeature(feature, info); err != nil {
+			return fmt.Errorf("failed to validate feature %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Posts the fields to one of the edit operations of the layer and decodes the
+// results
+func (l *Layer) editFeatures(ctx context.Context, operation string, fields map[string]string) (results LayerEditResults, err error) {
+	u, err := url.Parse(l.fs.url)
+	if err != nil {
+		return results, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	p, err := url.JoinPath(u.Path, fmt.Sprintf("%d", l.ID), operation)
+	if err != nil {
+		return results, fmt.Errorf("failed to join path: %w", err)
+	}
+
+	u.Path = p
+
+	formBody := &bytes.Buffer{}
+	formBodyWriter := multipart.NewWriter(formBody)
+
+	fieldNames := make([]string, 0, len(fields))
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	for _, name := range fieldNames {
+		if err := formBodyWriter.WriteField(name, fields[name]); err != nil {
+			return results, fmt.Errorf("failed to write '%s' field: %w", name, err)
+		}
+	}
+
+	if err := formBodyWriter.WriteField("f", "json"); err != nil {
+		return results, fmt.Errorf("failed to write 'f' field: %w", err)
+	}
+
+	if err := formBodyWriter.Close(); err != nil {
+		return results, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), formBody)
+	if err != nil {
+		return results, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", formBodyWriter.FormDataContentType())
+
+	resp, err := l.fs.httpClient.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("failed to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return results, ErrNotFound
+		default:
+			return results, fmt.Errorf("unhandled status code: %d", resp.StatusCode)
+		}
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return results, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var respJSON map[string]interface{}
+	if err := json.Unmarshal(respBody, &respJSON); err != nil {
+		return results, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	respError, ok := respJSON["error"]
+	if ok {
+		var errRespErr ErrResponseError
+		if err := mapstructure.Decode(respError, &errRespErr); err != nil {
+			return results, fmt.Errorf("failed to decode error response: %w", err)
+		}
+		return results, errRespErr
+	}
+
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return results, fmt.Errorf("failed to decode %s results: %w", operation, err)
+	}
+
+	results.LayerID = l.ID
+
+	return results, nil
+}
@@ -0,0 +1,316 @@
+package featureserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TheAschr/arcgis"
+)
+
+func TestLayerApplyEditsResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/applyEdits" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `[
+			{
+				"id": 0,
+				"addResults": [
+					{"objectId": 10, "globalId": "{A}", "success": true},
+					{"success": false, "error": {"code": 1000, "description": "Invalid geometry"}}
+				],
+				"updateResults": [{"objectId": 5, "success": true}],
+				"deleteResults": [{"objectId": 7, "success": false, "error": {"code": 1019, "description": "Object is missing"}}]
+			},
+			{
+				"id": 1,
+				"addResults": [{"objectId": 3, "success": true}]
+			}
+		]`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	results, err := fsc.Layer(0).ApplyEdits(context.Background(), ApplyEditsVariables{})
+	if err != nil {
+		t.Fatalf("failed to apply edits: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 layer results, got: %d", len(results))
+	}
+
+	added := results[0].AddResults[0]
+	if added.ObjectID != 10 || added.GlobalID != "{A}" || !added.Success || added.Error != nil {
+		t.Errorf("unexpected add result: %+v", added)
+	}
+
+	failed := results[0].AddResults[1]
+	if failed.Success || failed.Error == nil || failed.Error.Code != 1000 || failed.Error.Description != "Invalid geometry" {
+		t.Errorf("unexpected failed add result: %+v", failed)
+	}
+
+	if err := results[1].Err(); err != nil {
+		t.Errorf("expected no error for layer 1, got: %v", err)
+	}
+
+	err = ApplyEditsError(results)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, expected := range []string{
+		"layer 0: add 1 failed: code: 1000, description: 'Invalid geometry'",
+		"layer 0: delete 0 of object id 7 failed: code: 1019, description: 'Object is missing'",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain '%s', got: %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "update") || strings.Contains(err.Error(), "layer 1") {
+		t.Errorf("expected only failed edits in error, got: %v", err)
+	}
+
+	var editErr ErrEditFailed
+	if !errors.As(err, &editErr) {
+		t.Errorf("expected error to wrap ErrEditFailed")
+	}
+}
+
+func TestLayerFeatureEdits(t *testing.T) {
+	type Attributes struct {
+		ObjectID int32  `json:"objectid"`
+		Name     string `json:"name"`
+	}
+
+	type PointFeature struct {
+		Attributes Attributes    `json:"attributes"`
+		Geometry   GeometryPoint `json:"geometry"`
+	}
+
+	type PolygonFeature struct {
+		Attributes Attributes      `json:"attributes"`
+		Geometry   GeometryPolygon `json:"geometry"`
+	}
+
+	var requests []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/0":
+			fmt.Fprint(w, `{"id":0,"name":"points","type":"Feature Layer","geometryType":"esriGeometryPoint","objectIdField":"objectid","fields":[{"name":"objectid","type":"esriFieldTypeOID"},{"name":"name","type":"esriFieldTypeString"}]}`)
+		case "/0/addFeatures":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse form: %v", err)
+				return
+			}
+			if expected := `[{"attributes":{"objectid":0,"name":"a"},"geometry":{"x":1,"y":2}}]`; r.FormValue("features") != expected {
+				t.Errorf("expected features '%s', got: '%s'", expected, r.FormValue("features"))
+			}
+			fmt.Fprint(w, `{"addResults":[{"objectId":11,"success":true}]}`)
+		case "/0/updateFeatures":
+			fmt.Fprint(w, `{"updateResults":[{"objectId":11,"success":false,"error":{"code":1019,"description":"Object is missing"}}]}`)
+		case "/0/deleteFeatures":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse form: %v", err)
+				return
+			}
+			expected := map[string]string{
+				"objectIds":    "11,12",
+				"where":        "name = 'a'",
+				"geometryType": GeometryTypeEnvelope,
+				"spatialRel":   SpatialRelWithin,
+			}
+			for name, value := range expected {
+				if r.FormValue(name) != value {
+					t.Errorf("expected %s '%s', got: '%s'", name, value, r.FormValue(name))
+				}
+			}
+			fmt.Fprint(w, `{"deleteResults":[{"objectId":11,"success":true},{"objectId":12,"success":true}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+	layer := fsc.Layer(0)
+	ctx := context.Background()
+
+	t.Run("Add", func(t *testing.T) {
+		results, err := layer.AddFeatures(ctx, AddFeaturesVariables{
+			Features: []AddOperation{PointFeature{Attributes: Attributes{Name: "a"}, Geometry: GeometryPoint{X: 1, Y: 2}}},
+		})
+		if err != nil {
+			t.Fatalf("failed to add features: %v", err)
+		}
+		if len(results) != 1 || results[0].ObjectID != 11 || !results[0].Success {
+			t.Errorf("unexpected add results: %+v", results)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		results, err := layer.UpdateFeatures(ctx, UpdateFeaturesVariables{
+			Features: []UpdateOperation{PointFeature{Attributes: Attributes{ObjectID: 11}, Geometry: GeometryPoint{X: 1, Y: 2}}},
+		})
+		if err != nil {
+			t.Fatalf("failed to update features: %v", err)
+		}
+		if len(results) != 1 || results[0].Success || results[0].Error == nil || results[0].Error.Code != 1019 {
+			t.Errorf("unexpected update results: %+v", results)
+		}
+	})
+
+	t.Run("Invalid feature is not sent", func(t *testing.T) {
+		requests = nil
+		_, err := layer.AddFeatures(ctx, AddFeaturesVariables{
+			Features: []AddOperation{PolygonFeature{}},
+		})
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+		for _, path := range requests {
+			if path == "/0/addFeatures" {
+				t.Errorf("expected invalid features not to be sent")
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		results, err := layer.DeleteFeatures(ctx, DeleteFeaturesVariables{
+			ObjectIDs:  []int{11, 12},
+			Where:      "name = 'a'",
+			Geometry:   GeometryEnvelope{XMin: 0, YMin: 0, XMax: 10, YMax: 10},
+			SpatialRel: SpatialRelWithin,
+		})
+		if err != nil {
+			t.Fatalf("failed to delete features: %v", err)
+		}
+		if len(results) != 2 || !results[0].Success || !results[1].Success {
+			t.Errorf("unexpected delete results: %+v", results)
+		}
+	})
+
+	t.Run("Delete without filter", func(t *testing.T) {
+		if _, err := layer.DeleteFeatures(ctx, DeleteFeaturesVariables{}); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
+
+func TestLayerApplyEditsOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+		expected := map[string]string{
+			"rollbackOnFailure":        "false",
+			"useGlobalIds":             "true",
+			"returnEditMoment":         "true",
+			"returnServiceEditsOption": ReturnServiceEditsOptionOriginalAndCurrentFeatures,
+			"gdbVersion":               "sde.edits",
+			"sessionID":                "{S}",
+		}
+		for name, value := range expected {
+			if r.FormValue(name) != value {
+				t.Errorf("expected %s '%s', got: '%s'", name, value, r.FormValue(name))
+			}
+		}
+		fmt.Fprint(w, `[{
+			"id": 0,
+			"editMoment": 1700000000123,
+			"editedFeatures": {
+				"spatialReference": {"wkid": 3857},
+				"adds": [
+					{"attributes": {"globalid": "{A}"}, "geometry": {"x": 1, "y": 2}},
+					{"attributes": {"globalid": "{D}"}, "geometry": {"paths": [[[1, 2, 10], [3, 4, 20]]]}},
+					{"attributes": {"globalid": "{E}"}, "geometry": {"rings": [[[0, 0, 1, 5], [0, 1, 2, 6], [1, 1, 3, 7], [0, 0, 1, 5]]]}}
+				],
+				"updates": [[
+					{"attributes": {"globalid": "{B}"}, "geometry": {"x": 3, "y": 4}},
+					{"attributes": {"globalid": "{B}"}, "geometry": {"x": 5, "y": 6}}
+				]],
+				"deletes": [{"attributes": {"globalid": "{C}"}}]
+			},
+			"addResults": [{"globalId": "{A}", "success": true}],
+			"updateResults": [{"globalId": "{B}", "success": true}],
+			"deleteResults": [{"globalId": "{C}", "success": true}]
+		}]`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	results, err := fsc.Layer(0).ApplyEdits(context.Background(), ApplyEditsVariables{
+		Edits:                    []Edit{{LayerID: 0, Deletes: []DeleteOperation{"{C}"}}},
+		RollbackOnFailure:        arcgis.Nullable(false),
+		UseGlobalIds:             true,
+		ReturnEditMoment:         true,
+		ReturnServiceEditsOption: ReturnServiceEditsOptionOriginalAndCurrentFeatures,
+		GdbVersion:               "sde.edits",
+		SessionID:                "{S}",
+	})
+	if err != nil {
+		t.Fatalf("failed to apply edits: %v", err)
+	}
+
+	layerResults := results[0]
+	if layerResults.EditMoment.Time == nil || layerResults.EditMoment.UnixMilli() != 1700000000123 {
+		t.Errorf("expected edit moment 1700000000123, got: %v", layerResults.EditMoment.Time)
+	}
+	if layerResults.DeleteResults[0].GlobalID != "{C}" {
+		t.Errorf("expected deleted global id '{C}', got: '%s'", layerResults.DeleteResults[0].GlobalID)
+	}
+
+	edited := layerResults.EditedFeatures
+	if edited == nil {
+		t.Fatalf("expected edited features")
+	}
+	if len(edited.Adds) != 3 || len(edited.Updates) != 1 || len(edited.Deletes) != 1 {
+		t.Fatalf("expected 3 adds, 1 update and 1 delete, got: %d, %d and %d", len(edited.Adds), len(edited.Updates), len(edited.Deletes))
+	}
+
+	polyline, ok := edited.Adds[1].Geometry.(GeometryPolyline)
+	if !ok || !polyline.HasZ || polyline.HasM {
+		t.Fatalf("expected polyline with z values, got: %+v", edited.Adds[1].Geometry)
+	}
+	if z := polyline.Paths[0][1].Z; z == nil || *z != 20 {
+		t.Errorf("expected z value 20, got: %v", z)
+	}
+	polygon, ok := edited.Adds[2].Geometry.(GeometryPolygon)
+	if !ok || !polygon.HasZ || !polygon.HasM {
+		t.Fatalf("expected polygon with z and m values, got: %+v", edited.Adds[2].Geometry)
+	}
+	if c := polygon.Rings[0][2]; c.Z == nil || *c.Z != 3 || c.M == nil || *c.M != 7 {
+		t.Errorf("expected z value 3 and m value 7, got: %v and %v", c.Z, c.M)
+	}
+
+	original, ok := edited.Updates[0].Original.Geometry.(GeometryPoint)
+	if !ok || original.X != 3 || original.Y != 4 {
+		t.Errorf("expected original point (3, 4), got: %+v", edited.Updates[0].Original.Geometry)
+	}
+	current, ok := edited.Updates[0].Current.Geometry.(GeometryPoint)
+	if !ok || current.X != 5 || current.Y != 6 || current.SpatialReference == nil || current.SpatialReference.WKID != 3857 {
+		t.Errorf("expected current point (5, 6) in 3857, got: %+v", edited.Updates[0].Current.Geometry)
+	}
+	if _, ok := edited.Deletes[0].Geometry.(GeometryNone); !ok {
+		t.Errorf("expected no geometry for deleted feature, got: %T", edited.Deletes[0].Geometry)
+	}
+}
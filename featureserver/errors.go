@@ -26,3 +26,18 @@ func (err ErrResponseError) Is(target error) bool {
 	_, ok := target.(ErrResponseError)
 	return ok
 }
+
+// The error of a single add, update or delete that failed
+type ErrEditFailed struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+func (err ErrEditFailed) Error() string {
+	return fmt.Sprintf("code: %d, description: '%s'", err.Code, err.Description)
+}
+
+func (err ErrEditFailed) Is(target error) bool {
+	_, ok := target.(ErrEditFailed)
+	return ok
+}
@@ -0,0 +1,209 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultExtractChunkSize   = 1000
+	defaultExtractConcurrency = 4
+)
+
+type ExtractOptions struct {
+	// Number of object ids queried per request. Defaults to the layer's
+	// maxRecordCount and is never larger than it.
+	ChunkSize int
+	// Number of requests made at the same time. The default is 4.
+	Concurrency int
+}
+
+// Queries all the features matching the query without relying on resultOffset,
+// for layers that don't support pagination. The object ids are fetched first
+// and then queried in chunks by a bounded number of workers.
+//
+// fn is called with the results of each chunk in ascending object id order and
+// the features of each chunk are sorted by object id. Returning an error from
+// fn stops the extraction.
+func (l *Layer) Extract(ctx context.Context, variables QueryVariables, options ExtractOptions, fn func(results QueryResults) error) error {
+	info, err := l.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get layer info: %w", err)
+	}
+
+	oidField, err := objectIDField(info)
+	if err != nil {
+		return err
+	}
+
+	var maxRecordCount int
+	switch info := info.(type) {
+	case FeatureLayerInfo:
+		maxRecordCount = info.MaxRecordCount
+	case TableInfo:
+		maxRecordCount = info.MaxRecordCount
+	}
+
+	chunkSize := options.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = maxRecordCount
+	}
+	if maxRecordCount > 0 && chunkSize > maxRecordCount {
+		chunkSize = maxRecordCount
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultExtractChunkSize
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultExtractConcurrency
+	}
+
+	idsVariables := variables
+	idsVariables.ResultRecordCount = -1
+	idsVariables.ResultOffset = 0
+	idsVariables.OrderByFields = nil
+
+	ids, err := l.QueryIDs(ctx, idsVariables)
+	if err != nil {
+		return fmt.Errorf("failed to query object ids: %w", err)
+	}
+
+	objectIDs := ids.ObjectIDs
+	sort.Ints(objectIDs)
+
+	var chunks [][]int
+	for len(objectIDs) > 0 {
+		n := chunkSize
+		if n > len(objectIDs) {
+			n = len(objectIDs)
+		}
+		chunks = append(chunks, objectIDs[:n])
+		objectIDs = objectIDs[n:]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type chunkResults struct {
+		results QueryResults
+		err     error
+	}
+
+	chunkResultsChs := make([]chan chunkResults, len(chunks))
+	for i := range chunkResultsChs {
+		chunkResultsChs[i] = make(chan chunkResults, 1)
+	}
+
+	// A worker slot is only released once its chunk has been handed to fn, so
+	// at most concurrency chunks are held in memory at a time.
+	slots := make(chan struct{}, concurrency)
+
+	go func() {
+		for i, chunk := range chunks {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(ch chan chunkResults, chunk []int) {
+				chunkVariables := variables
+				chunkVariables.Where = ""
+				chunkVariables.ObjectIDs = chunk
+				chunkVariables.Geometry = nil
+				chunkVariables.ResultRecordCount = -1
+				chunkVariables.ResultOffset = 0
+				chunkVariables.OrderByFields = nil
+				chunkVariables.OutFields = withObjectIDField(variables.OutFields, oidField)
+
+				results, err := l.Query(ctx, chunkVariables)
+				if err == nil {
+					err = sortFeaturesByObjectID(results.Features, oidField)
+				}
+				ch <- chunkResults{results, err}
+			}(chunkResultsChs[i], chunk)
+		}
+	}()
+
+	for i, ch := range chunkResultsChs {
+		var r chunkResults
+		select {
+		case r = <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-slots
+
+		if r.err != nil {
+			return fmt.Errorf("failed to query object ids %d to %d: %w", chunks[i][0], chunks[i][len(chunks[i])-1], r.err)
+		}
+
+		if err := fn(r.results); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type featuresByObjectID struct {
+	features  []Feature
+	objectIDs []int
+}
+
+func (f featuresByObjectID) Len() int           { return len(f.features) }
+func (f featuresByObjectID) Less(i, j int) bool { return f.objectIDs[i] < f.objectIDs[j] }
+func (f featuresByObjectID) Swap(i, j int) {
+	f.features[i], f.features[j] = f.features[j], f.features[i]
+	f.objectIDs[i], f.objectIDs[j] = f.objectIDs[j], f.objectIDs[i]
+}
+
+// Adds the object id field to the out fields unless it's already requested,
+// the features can't be ordered without it
+func withObjectIDField(outFields []string, oidField string) []string {
+	for _, list := range outFields {
+		for _, field := range strings.Split(list, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, oidField) {
+				return outFields
+			}
+		}
+	}
+	return append(append([]string(nil), outFields...), oidField)
+}
+
+func sortFeaturesByObjectID(features []Feature, oidField string) error {
+	objectIDs := make([]int, len(features))
+
+	for i, f := range features {
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(f.Attributes, &attributes); err != nil {
+			return fmt.Errorf("failed to unmarshal attributes: %w", err)
+		}
+		objectID, ok := attributes[oidField]
+		if !ok {
+			// The server may return the field name in a different case
+			for name, value := range attributes {
+				if strings.EqualFold(name, oidField) {
+					objectID, ok = value, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return fmt.Errorf("missing object id field '%s' in attributes", oidField)
+		}
+		if err := json.Unmarshal(objectID, &objectIDs[i]); err != nil {
+			return fmt.Errorf("failed to unmarshal object id: %w", err)
+		}
+	}
+
+	sort.Stable(featuresByObjectID{features, objectIDs})
+
+	return nil
+}
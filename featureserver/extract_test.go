@@ -0,0 +1,185 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLayerExtract(t *testing.T) {
+	t.Run("Chunks are returned in object id order", func(t *testing.T) {
+		const totalFeatures = 95
+		const maxRecordCount = 10
+		const concurrency = 3
+
+		var inFlight, maxInFlight int32
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/0":
+				fmt.Fprintf(w, `{"id":0,"name":"points","type":"Feature Layer","objectIdField":"objectid","maxRecordCount":%d}`, maxRecordCount)
+			case "/0/query":
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("failed to parse form: %v", err)
+					return
+				}
+
+				if r.FormValue("returnIdsOnly") == "true" {
+					// Return the ids unordered to make sure they are sorted
+					var objectIDs []int
+					for i := totalFeatures; i > 0; i-- {
+						objectIDs = append(objectIDs, i)
+					}
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"objectIdFieldName": "objectid",
+						"objectIds":         objectIDs,
+					})
+					return
+				}
+
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					m := atomic.LoadInt32(&maxInFlight)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+						break
+					}
+				}
+
+				objectIDs := strings.Split(r.FormValue("objectIds"), ",")
+				if len(objectIDs) > maxRecordCount {
+					t.Errorf("expected at most %d object ids, got: %d", maxRecordCount, len(objectIDs))
+				}
+
+				// Return the features in reverse to make sure they are sorted
+				var features []map[string]interface{}
+				for i := len(objectIDs) - 1; i >= 0; i-- {
+					objectID, _ := strconv.Atoi(objectIDs[i])
+					features = append(features, map[string]interface{}{
+						"attributes": map[string]interface{}{"objectid": objectID},
+					})
+				}
+
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"objectIdFieldName": "objectid",
+					"features":          features,
+				})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		var objectIDs []int
+		var chunks int
+
+		err = fsc.Layer(0).Extract(context.Background(), QueryVariables{Where: "1=1"}, ExtractOptions{Concurrency: concurrency}, func(results QueryResults) error {
+			chunks++
+			for _, f := range results.Features {
+				var attributes struct {
+					ObjectID int `json:"objectid"`
+				}
+				if err := json.Unmarshal(f.Attributes, &attributes); err != nil {
+					return err
+				}
+				objectIDs = append(objectIDs, attributes.ObjectID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to extract: %v", err)
+		}
+
+		if chunks != 10 {
+			t.Errorf("expected 10 chunks, got: %d", chunks)
+		}
+
+		if len(objectIDs) != totalFeatures {
+			t.Fatalf("expected %d features, got: %d", totalFeatures, len(objectIDs))
+		}
+
+		for i, objectID := range objectIDs {
+			if objectID != i+1 {
+				t.Fatalf("expected object id %d at %d, got: %d", i+1, i, objectID)
+			}
+		}
+
+		if m := atomic.LoadInt32(&maxInFlight); m > concurrency {
+			t.Errorf("expected at most %d concurrent requests, got: %d", concurrency, m)
+		}
+	})
+}
+
+func TestLayerExtractOutFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0":
+			fmt.Fprint(w, `{"id":0,"name":"points","type":"Feature Layer","objectIdField":"objectid","maxRecordCount":2}`)
+		case "/0/query":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse form: %v", err)
+				return
+			}
+
+			if r.FormValue("returnIdsOnly") == "true" {
+				fmt.Fprint(w, `{"objectIdFieldName":"objectid","objectIds":[3,1,2]}`)
+				return
+			}
+
+			if outFields := r.FormValue("outFields"); outFields != "name,objectid" {
+				t.Errorf("expected outFields 'name,objectid', got: '%s'", outFields)
+			}
+
+			// The object id field is returned in a different case than the layer info
+			var features []map[string]interface{}
+			objectIDs := strings.Split(r.FormValue("objectIds"), ",")
+			for i := len(objectIDs) - 1; i >= 0; i-- {
+				objectID, _ := strconv.Atoi(objectIDs[i])
+				features = append(features, map[string]interface{}{
+					"attributes": map[string]interface{}{"OBJECTID": objectID, "name": fmt.Sprintf("feature %d", objectID)},
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"features": features})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	var names []string
+	err = fsc.Layer(0).Extract(context.Background(), QueryVariables{Where: "1=1", OutFields: []string{"name"}}, ExtractOptions{}, func(results QueryResults) error {
+		for _, f := range results.Features {
+			var attributes struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(f.Attributes, &attributes); err != nil {
+				return err
+			}
+			names = append(names, attributes.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+
+	if expected := "[feature 1 feature 2 feature 3]"; fmt.Sprint(names) != expected {
+		t.Errorf("expected %s, got: %v", expected, names)
+	}
+}
@@ -10,5 +10,8 @@ type Feature struct {
 	//  - GeometryNone
 	//  - GeometryPoint
 	//  - GeometryMultiPoint
+	//  - GeometryPolyline
+	//  - GeometryPolygon
+	//  - GeometryEnvelope
 	Geometry interface{} `json:"geometry"`
 }
@@ -0,0 +1,433 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	GeoJSONTypeFeatureCollection = "FeatureCollection"
+	GeoJSONTypeFeature           = "Feature"
+	GeoJSONTypePoint             = "Point"
+	GeoJSONTypeMultiPoint        = "MultiPoint"
+	GeoJSONTypeLineString        = "LineString"
+	GeoJSONTypeMultiLineString   = "MultiLineString"
+	GeoJSONTypePolygon           = "Polygon"
+	GeoJSONTypeMultiPolygon      = "MultiPolygon"
+)
+
+type GeoJSONFeatureCollection struct {
+	// Should be GeoJSONTypeFeatureCollection
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+	// Arcgis sets exceededTransferLimit here when there are more features than were returned
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type GeoJSONFeature struct {
+	// Should be GeoJSONTypeFeature
+	Type       string           `json:"type"`
+	ID         interface{}      `json:"id,omitempty"`
+	Geometry   *GeoJSONGeometry `json:"geometry"`
+	Properties json.RawMessage  `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	// Can be one of:
+	//  - GeoJSONTypePoint
+	//  - GeoJSONTypeMultiPoint
+	//  - GeoJSONTypeLineString
+	//  - GeoJSONTypeMultiLineString
+	//  - GeoJSONTypePolygon
+	//  - GeoJSONTypeMultiPolygon
+	Type string `json:"type"`
+	// Nesting depends on the type, from a single position for a point to
+	// polygons of rings of positions for a multipolygon
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Queries the layer with f=geojson and returns the feature collection as is.
+// The server returns the geometries in WGS84 regardless of OutSR.
+func (l *Layer) QueryGeoJSON(ctx context.Context, variables QueryVariables) (fc GeoJSONFeatureCollection, err error) {
+	variables.Format = FormatGeoJSON
+
+	body, err := l.queryBody(ctx, variables, nil)
+	if err != nil {
+		return fc, err
+	}
+	defer body.Close()
+
+	return decodeGeoJSONFeatureCollection(body)
+}
+
+func decodeGeoJSONFeatureCollection(r io.Reader) (fc GeoJSONFeatureCollection, err error) {
+	var resp struct {
+		GeoJSONFeatureCollection
+		Error *ErrResponseError `json:"error"`
+	}
+	if err := json.NewDecoder(r).Decode(&resp); err != nil {
+		return fc, fmt.Errorf("failed to decode geojson feature collection: %w", err)
+	}
+	if resp.Error != nil {
+		return fc, *resp.Error
+	}
+	return resp.GeoJSONFeatureCollection, nil
+}
+
+// Decodes a f=geojson response in to query results, converting the features
+// the same way as GeoJSONToFeatures.
+func decodeGeoJSONQueryResults(r io.Reader, returnGeometry bool, fn func(f Feature) error) (results QueryResults, err error) {
+	fc, err := decodeGeoJSONFeatureCollection(r)
+	if err != nil {
+		return results, err
+	}
+
+	exceededTransferLimit, _ := fc.Properties["exceededTransferLimit"].(bool)
+	results.ExceededTransferLimit = exceededTransferLimit
+	results.SpatialReference = &SpatialReference{WKID: 4326}
+
+	features, err := GeoJSONToFeatures(fc)
+	if err != nil {
+		return results, err
+	}
+
+	for _, f := range features {
+		if !returnGeometry {
+			f.Geometry = GeometryNone{}
+		} else if results.GeometryType == GeometryTypeNone {
+			results.GeometryType, _ = geometryTypeOf(f.Geometry)
+		}
+		if err := fn(f); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+// Converts query results to a GeoJSON feature collection. The coordinates are
+// not reprojected so the results should be queried with an OutSR of WGS84
+// (wkid 4326) to get valid GeoJSON. M values are dropped.
+func QueryResultsToGeoJSON(results QueryResults) (fc GeoJSONFeatureCollection, err error) {
+	fc.Type = GeoJSONTypeFeatureCollection
+	fc.Features = make([]GeoJSONFeature, len(results.Features))
+
+	if results.ExceededTransferLimit {
+		fc.Properties = map[string]interface{}{"exceededTransferLimit": true}
+	}
+
+	for i, f := range results.Features {
+		feature := GeoJSONFeature{
+			Type:       GeoJSONTypeFeature,
+			Properties: f.Attributes,
+		}
+
+		if results.ObjectIDFieldName != "" && len(f.Attributes) > 0 {
+			var attributes map[string]interface{}
+			if err := json.Unmarshal(f.Attributes, &attributes); err != nil {
+				return fc, fmt.Errorf("failed to unmarshal attributes: %w", err)
+			}
+			feature.ID = attributes[results.ObjectIDFieldName]
+		}
+
+		if feature.Geometry, err = GeometryToGeoJSON(f.Geometry); err != nil {
+			return fc, err
+		}
+
+		fc.Features[i] = feature
+	}
+
+	return fc, nil
+}
+
+// Converts a GeoJSON feature collection to features that can be used as adds
+// or updates of ApplyEdits. The geometries get a spatial reference of WGS84.
+func GeoJSONToFeatures(fc GeoJSONFeatureCollection) ([]Feature, error) {
+	features := make([]Feature, len(fc.Features))
+
+	for i, f := range fc.Features {
+		geometry, err := GeometryFromGeoJSON(f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert feature %d: %w", i, err)
+		}
+
+		attributes := f.Properties
+		if len(attributes) == 0 || string(attributes) == "null" {
+			attributes = json.RawMessage("{}")
+		}
+
+		features[i] = Feature{
+			Attributes: attributes,
+			Geometry:   geometry,
+		}
+	}
+
+	return features, nil
+}
+
+// Converts one of the geometry types to a GeoJSON geometry. Returns nil for
+// GeometryNone. Polyline paths become a LineString or MultiLineString and
+// polygon rings are grouped in to a Polygon or MultiPolygon with the exterior
+// rings counterclockwise and the holes clockwise.
+func GeometryToGeoJSON(geometry interface{}) (*GeoJSONGeometry, error) {
+	var geometryType string
+	var coordinates interface{}
+
+	switch g := geometry.(type) {
+	case nil, GeometryNone:
+		return nil, nil
+	case GeometryPoint:
+		geometryType = GeoJSONTypePoint
+		coordinates = geoJSONPosition(Coordinate{X: g.X, Y: g.Y, Z: g.Z})
+	case GeometryMultiPoint:
+		geometryType = GeoJSONTypeMultiPoint
+		coordinates = geoJSONPositions(g.Points)
+	case GeometryPolyline:
+		if len(g.Paths) == 1 {
+			geometryType = GeoJSONTypeLineString
+			coordinates = geoJSONPositions(g.Paths[0])
+		} else {
+			geometryType = GeoJSONTypeMultiLineString
+			paths := make([][][]float64, len(g.Paths))
+			for i, path := range g.Paths {
+				paths[i] = geoJSONPositions(path)
+			}
+			coordinates = paths
+		}
+	case GeometryPolygon:
+		polygons := polygonsFromRings(g.Rings)
+		multiPolygon := make([][][][]float64, len(polygons))
+		for i, polygon := range polygons {
+			multiPolygon[i] = make([][][]float64, len(polygon))
+			for j, ring := range polygon {
+				// Esri exteriors are clockwise, GeoJSON exteriors are counterclockwise
+				multiPolygon[i][j] = geoJSONPositions(reverseRing(ring))
+			}
+		}
+		if len(multiPolygon) == 1 {
+			geometryType = GeoJSONTypePolygon
+			coordinates = multiPolygon[0]
+		} else {
+			geometryType = GeoJSONTypeMultiPolygon
+			coordinates = multiPolygon
+		}
+	case GeometryEnvelope:
+		geometryType = GeoJSONTypePolygon
+		coordinates = [][][]float64{{
+			{g.XMin, g.YMin},
+			{g.XMax, g.YMin},
+			{g.XMax, g.YMax},
+			{g.XMin, g.YMax},
+			{g.XMin, g.YMin},
+		}}
+	default:
+		return nil, fmt.Errorf("unhandled geometry: %T", geometry)
+	}
+
+	coordinatesJSON, err := json.Marshal(coordinates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal geojson coordinates: %w", err)
+	}
+
+	return &GeoJSONGeometry{Type: geometryType, Coordinates: coordinatesJSON}, nil
+}
+
+// Converts a GeoJSON geometry to one of the geometry types. Returns
+// GeometryNone for a nil geometry. Polygon rings are oriented the way Esri
+// expects them, exterior rings clockwise and holes counterclockwise.
+func GeometryFromGeoJSON(geometry *GeoJSONGeometry) (interface{}, error) {
+	if geometry == nil {
+		return GeometryNone{}, nil
+	}
+
+	sr := &SpatialReference{WKID: 4326}
+
+	switch geometry.Type {
+	case GeoJSONTypePoint:
+		var position []float64
+		if err := json.Unmarshal(geometry.Coordinates, &position); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal point coordinates: %w", err)
+		}
+		c, err := coordinateFromGeoJSON(position)
+		if err != nil {
+			return nil, err
+		}
+		return GeometryPoint{X: c.X, Y: c.Y, Z: c.Z, SpatialReference: sr}, nil
+	case GeoJSONTypeMultiPoint, GeoJSONTypeLineString:
+		var positions [][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &positions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s coordinates: %w", geometry.Type, err)
+		}
+		coordinates, hasZ, err := coordinatesFromGeoJSON(positions)
+		if err != nil {
+			return nil, err
+		}
+		if geometry.Type == GeoJSONTypeMultiPoint {
+			return GeometryMultiPoint{HasZ: hasZ, Points: coordinates, SpatialReference: sr}, nil
+		}
+		return GeometryPolyline{HasZ: hasZ, Paths: [][]Coordinate{coordinates}, SpatialReference: sr}, nil
+	case GeoJSONTypeMultiLineString, GeoJSONTypePolygon:
+		var lines [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &lines); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s coordinates: %w", geometry.Type, err)
+		}
+		parts, hasZ, err := partsFromGeoJSON(lines)
+		if err != nil {
+			return nil, err
+		}
+		if geometry.Type == GeoJSONTypeMultiLineString {
+			return GeometryPolyline{HasZ: hasZ, Paths: parts, SpatialReference: sr}, nil
+		}
+		return GeometryPolygon{HasZ: hasZ, Rings: orientPolygonRings(parts), SpatialReference: sr}, nil
+	case GeoJSONTypeMultiPolygon:
+		var polygons [][][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal multipolygon coordinates: %w", err)
+		}
+		var rings [][]Coordinate
+		hasZ := false
+		for _, polygon := range polygons {
+			parts, z, err := partsFromGeoJSON(polygon)
+			if err != nil {
+				return nil, err
+			}
+			hasZ = hasZ || z
+			rings = append(rings, orientPolygonRings(parts)...)
+		}
+		return GeometryPolygon{HasZ: hasZ, Rings: rings, SpatialReference: sr}, nil
+	default:
+		return nil, fmt.Errorf("unhandled geojson geometry type: %s", geometry.Type)
+	}
+}
+
+func geoJSONPosition(c Coordinate) []float64 {
+	if c.Z != nil {
+		return []float64{c.X, c.Y, *c.Z}
+	}
+	return []float64{c.X, c.Y}
+}
+
+func geoJSONPositions(coordinates []Coordinate) [][]float64 {
+	positions := make([][]float64, len(coordinates))
+	for i, c := range coordinates {
+		positions[i] = geoJSONPosition(c)
+	}
+	return positions
+}
+
+func coordinateFromGeoJSON(position []float64) (c Coordinate, err error) {
+	if len(position) < 2 {
+		return c, fmt.Errorf("geojson position must have at least 2 values but has %d", len(position))
+	}
+	c.X, c.Y = position[0], position[1]
+	if len(position) > 2 {
+		z := position[2]
+		c.Z = &z
+	}
+	return c, nil
+}
+
+func coordinatesFromGeoJSON(positions [][]float64) (coordinates []Coordinate, hasZ bool, err error) {
+	coordinates = make([]Coordinate, len(positions))
+	for i, position := range positions {
+		if coordinates[i], err = coordinateFromGeoJSON(position); err != nil {
+			return nil, false, err
+		}
+		hasZ = hasZ || coordinates[i].Z != nil
+	}
+	return coordinates, hasZ, nil
+}
+
+func partsFromGeoJSON(lines [][][]float64) (parts [][]Coordinate, hasZ bool, err error) {
+	parts = make([][]Coordinate, len(lines))
+	for i, line := range lines {
+		var z bool
+		if parts[i], z, err = coordinatesFromGeoJSON(line); err != nil {
+			return nil, false, err
+		}
+		hasZ = hasZ || z
+	}
+	return parts, hasZ, nil
+}
+
+// Orients the rings of a single GeoJSON polygon, the first ring being the
+// exterior, the way Esri expects them.
+func orientPolygonRings(rings [][]Coordinate) [][]Coordinate {
+	oriented := make([][]Coordinate, len(rings))
+	for i, ring := range rings {
+		clockwise := ringArea(ring) < 0
+		if (i == 0) != clockwise {
+			ring = reverseRing(ring)
+		}
+		oriented[i] = ring
+	}
+	return oriented
+}
+
+// Groups Esri polygon rings in to polygons, each being an exterior ring
+// followed by its holes. Clockwise rings are exteriors and counterclockwise
+// rings are holes of the smallest exterior containing them.
+func polygonsFromRings(rings [][]Coordinate) [][][]Coordinate {
+	var polygons [][][]Coordinate
+	var holes [][]Coordinate
+
+	for _, ring := range rings {
+		if ringArea(ring) <= 0 {
+			polygons = append(polygons, [][]Coordinate{ring})
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	for _, hole := range holes {
+		owner := -1
+		for i, polygon := range polygons {
+			if len(hole) == 0 || !ringContains(polygon[0], hole[0]) {
+				continue
+			}
+			if owner == -1 || -ringArea(polygon[0]) < -ringArea(polygons[owner][0]) {
+				owner = i
+			}
+		}
+		if owner == -1 {
+			// A hole outside of every exterior is treated as an exterior with
+			// the wrong orientation
+			polygons = append(polygons, [][]Coordinate{reverseRing(hole)})
+			continue
+		}
+		polygons[owner] = append(polygons[owner], hole)
+	}
+
+	return polygons
+}
+
+// Signed area of a ring, positive when counterclockwise and negative when clockwise
+func ringArea(ring []Coordinate) float64 {
+	area := 0.0
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i].X*ring[j].Y - ring[j].X*ring[i].Y
+	}
+	return area / 2
+}
+
+func ringContains(ring []Coordinate, c Coordinate) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > c.Y) != (b.Y > c.Y) && c.X < (b.X-a.X)*(c.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func reverseRing(ring []Coordinate) []Coordinate {
+	reversed := make([]Coordinate, len(ring))
+	for i, c := range ring {
+		reversed[len(ring)-1-i] = c
+	}
+	return reversed
+}
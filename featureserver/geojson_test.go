@@ -0,0 +1,111 @@
+package featureserver
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoJSON(t *testing.T) {
+	// Esri rings, exteriors clockwise and holes counterclockwise
+	exterior := []Coordinate{{X: 0, Y: 0}, {X: 0, Y: 10}, {X: 10, Y: 10}, {X: 10, Y: 0}, {X: 0, Y: 0}}
+	hole := []Coordinate{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 4}, {X: 2, Y: 4}, {X: 2, Y: 2}}
+	island := []Coordinate{{X: 20, Y: 20}, {X: 20, Y: 30}, {X: 30, Y: 30}, {X: 30, Y: 20}, {X: 20, Y: 20}}
+
+	t.Run("Polygon with hole", func(t *testing.T) {
+		g, err := GeometryToGeoJSON(GeometryPolygon{Rings: [][]Coordinate{exterior, hole}})
+		if err != nil {
+			t.Fatalf("failed to convert geometry: %v", err)
+		}
+		if g.Type != GeoJSONTypePolygon {
+			t.Fatalf("expected %s, got: %s", GeoJSONTypePolygon, g.Type)
+		}
+
+		var rings [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			t.Fatalf("failed to unmarshal coordinates: %v", err)
+		}
+		if len(rings) != 2 {
+			t.Fatalf("expected 2 rings, got: %d", len(rings))
+		}
+
+		for i, expectCounterclockwise := range []bool{true, false} {
+			ring := make([]Coordinate, len(rings[i]))
+			for j, p := range rings[i] {
+				ring[j] = Coordinate{X: p[0], Y: p[1]}
+			}
+			if (ringArea(ring) > 0) != expectCounterclockwise {
+				t.Errorf("expected ring %d counterclockwise to be %t", i, expectCounterclockwise)
+			}
+		}
+	})
+
+	t.Run("Multipolygon round trip", func(t *testing.T) {
+		g, err := GeometryToGeoJSON(GeometryPolygon{Rings: [][]Coordinate{exterior, hole, island}})
+		if err != nil {
+			t.Fatalf("failed to convert geometry: %v", err)
+		}
+		if g.Type != GeoJSONTypeMultiPolygon {
+			t.Fatalf("expected %s, got: %s", GeoJSONTypeMultiPolygon, g.Type)
+		}
+
+		geometry, err := GeometryFromGeoJSON(g)
+		if err != nil {
+			t.Fatalf("failed to convert geojson: %v", err)
+		}
+		polygon, ok := geometry.(GeometryPolygon)
+		if !ok {
+			t.Fatalf("expected polygon, got: %T", geometry)
+		}
+		if len(polygon.Rings) != 3 {
+			t.Fatalf("expected 3 rings, got: %d", len(polygon.Rings))
+		}
+		for i, expectClockwise := range []bool{true, false, true} {
+			if (ringArea(polygon.Rings[i]) < 0) != expectClockwise {
+				t.Errorf("expected ring %d clockwise to be %t", i, expectClockwise)
+			}
+		}
+		if polygon.SpatialReference == nil || polygon.SpatialReference.WKID != 4326 {
+			t.Errorf("expected spatial reference 4326, got: %v", polygon.SpatialReference)
+		}
+	})
+
+	t.Run("Feature collection", func(t *testing.T) {
+		z := 5.0
+		results := QueryResults{
+			ObjectIDFieldName: "objectid",
+			Features: []Feature{
+				{Attributes: json.RawMessage(`{"objectid":3,"name":"a"}`), Geometry: GeometryPoint{X: 1, Y: 2, Z: &z}},
+				{Attributes: json.RawMessage(`{"objectid":4,"name":"b"}`), Geometry: GeometryPolyline{Paths: [][]Coordinate{{{X: 0, Y: 0}, {X: 1, Y: 1}}}}},
+			},
+		}
+
+		fc, err := QueryResultsToGeoJSON(results)
+		if err != nil {
+			t.Fatalf("failed to convert results: %v", err)
+		}
+		if fc.Type != GeoJSONTypeFeatureCollection || len(fc.Features) != 2 {
+			t.Fatalf("expected feature collection with 2 features, got: %s with %d", fc.Type, len(fc.Features))
+		}
+		if id, ok := fc.Features[0].ID.(float64); !ok || id != 3 {
+			t.Errorf("expected id 3, got: %v", fc.Features[0].ID)
+		}
+		if string(fc.Features[0].Geometry.Coordinates) != "[1,2,5]" {
+			t.Errorf("expected point [1,2,5], got: %s", fc.Features[0].Geometry.Coordinates)
+		}
+		if fc.Features[1].Geometry.Type != GeoJSONTypeLineString {
+			t.Errorf("expected %s, got: %s", GeoJSONTypeLineString, fc.Features[1].Geometry.Type)
+		}
+
+		features, err := GeoJSONToFeatures(fc)
+		if err != nil {
+			t.Fatalf("failed to convert feature collection: %v", err)
+		}
+		if string(features[1].Attributes) != `{"objectid":4,"name":"b"}` {
+			t.Errorf("expected attributes to round trip, got: %s", features[1].Attributes)
+		}
+		point, ok := features[0].Geometry.(GeometryPoint)
+		if !ok || point.Z == nil || *point.Z != 5 {
+			t.Errorf("expected point with z 5, got: %+v", features[0].Geometry)
+		}
+	})
+}
@@ -1,18 +1,373 @@
 package featureserver
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+)
+
 const (
 	GeometryTypeNone       = ""
 	GeometryTypePoint      = "esriGeometryPoint"
 	GeometryTypeMultiPoint = "esriGeometryMultipoint"
+	GeometryTypePolyline   = "esriGeometryPolyline"
+	GeometryTypePolygon    = "esriGeometryPolygon"
+	GeometryTypeEnvelope   = "esriGeometryEnvelope"
 )
 
 type GeometryNone struct{}
 
+// A single vertex of a multipoint, polyline or polygon. Arcgis encodes it as an
+// array of the form [x, y, <z>, <m>] where z and m are only present when the
+// geometry has z or m values.
+type Coordinate struct {
+	X float64
+	Y float64
+	// Only set when the geometry has z values
+	Z *float64
+	// Only set when the geometry has m values
+	M *float64
+}
+
+func (c Coordinate) MarshalJSON() ([]byte, error) {
+	values := []*float64{&c.X, &c.Y}
+	if c.Z != nil {
+		values = append(values, c.Z)
+	}
+	if c.M != nil {
+		values = append(values, c.M)
+	}
+	return json.Marshal(values)
+}
+
+// Three value coordinates are assumed to be [x, y, z] since the tuple alone
+// doesn't say whether the third value is z or m. Geometries decoded by a query
+// or unmarshalled as a multipoint, polyline or polygon use their hasZ and hasM
+// flags instead.
+func (c *Coordinate) UnmarshalJSON(b []byte) error {
+	var values []*float64
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	coordinate, err := newCoordinate(values, true, len(values) > 3)
+	if err != nil {
+		return err
+	}
+	*c = coordinate
+	return nil
+}
+
+func newCoordinate(values []*float64, hasZ bool, hasM bool) (c Coordinate, err error) {
+	if len(values) < 2 || values[0] == nil || values[1] == nil {
+		return c, fmt.Errorf("coordinate must have at least x and y values but got %d values", len(values))
+	}
+	c.X = *values[0]
+	c.Y = *values[1]
+	rest := values[2:]
+	if hasZ && len(rest) > 0 {
+		c.Z = rest[0]
+		rest = rest[1:]
+	}
+	if hasM && len(rest) > 0 {
+		c.M = rest[0]
+	}
+	return c, nil
+}
+
+// Builds the coordinates of a part from their values. If neither hasZ nor hasM
+// is set the values are read like Coordinate.UnmarshalJSON does.
+func newCoordinates(part [][]*float64, hasZ bool, hasM bool) ([]Coordinate, error) {
+	coordinates := make([]Coordinate, len(part))
+	for i, values := range part {
+		z, m := hasZ, hasM
+		if !hasZ && !hasM {
+			z, m = true, len(values) > 3
+		}
+		coordinate, err := newCoordinate(values, z, m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode coordinate %d: %w", i, err)
+		}
+		coordinates[i] = coordinate
+	}
+	return coordinates, nil
+}
+
+// Builds the coordinates of every part from their values
+func newParts(parts [][][]*float64, hasZ bool, hasM bool) ([][]Coordinate, error) {
+	coordinates := make([][]Coordinate, len(parts))
+	for i, part := range parts {
+		partCoordinates, err := newCoordinates(part, hasZ, hasM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode part %d: %w", i, err)
+		}
+		coordinates[i] = partCoordinates
+	}
+	return coordinates, nil
+}
+
 type GeometryMultiPoint struct {
-	Points [][]float64 `json:"points"`
+	HasZ             bool              `json:"hasZ,omitempty"`
+	HasM             bool              `json:"hasM,omitempty"`
+	Points           []Coordinate      `json:"points"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+// Reads the coordinates with the hasZ and hasM of the geometry
+func (g *GeometryMultiPoint) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		HasZ             bool              `json:"hasZ"`
+		HasM             bool              `json:"hasM"`
+		Points           [][]*float64      `json:"points"`
+		SpatialReference *SpatialReference `json:"spatialReference"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	points, err := newCoordinates(raw.Points, raw.HasZ, raw.HasM)
+	if err != nil {
+		return fmt.Errorf("failed to decode points: %w", err)
+	}
+	*g = GeometryMultiPoint{HasZ: raw.HasZ, HasM: raw.HasM, Points: points, SpatialReference: raw.SpatialReference}
+	return nil
 }
 
 type GeometryPoint struct {
 	X float64 `json:"x"`
 	Y float64 `json:"y"`
+	// Only set when the geometry has z values
+	Z *float64 `json:"z,omitempty"`
+	// Only set when the geometry has m values
+	M                *float64          `json:"m,omitempty"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+type GeometryPolyline struct {
+	HasZ bool `json:"hasZ,omitempty"`
+	HasM bool `json:"hasM,omitempty"`
+	// Each path is an array of points
+	Paths            [][]Coordinate    `json:"paths"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+// Reads the coordinates with the hasZ and hasM of the geometry
+func (g *GeometryPolyline) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		HasZ             bool              `json:"hasZ"`
+		HasM             bool              `json:"hasM"`
+		Paths            [][][]*float64    `json:"paths"`
+		SpatialReference *SpatialReference `json:"spatialReference"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	paths, err := newParts(raw.Paths, raw.HasZ, raw.HasM)
+	if err != nil {
+		return fmt.Errorf("failed to decode paths: %w", err)
+	}
+	*g = GeometryPolyline{HasZ: raw.HasZ, HasM: raw.HasM, Paths: paths, SpatialReference: raw.SpatialReference}
+	return nil
+}
+
+type GeometryPolygon struct {
+	HasZ bool `json:"hasZ,omitempty"`
+	HasM bool `json:"hasM,omitempty"`
+	// Each ring is a closed array of points. Exterior rings are clockwise and
+	// interior rings (holes) are counterclockwise.
+	Rings            [][]Coordinate    `json:"rings"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+// Reads the coordinates with the hasZ and hasM of the geometry
+func (g *GeometryPolygon) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		HasZ             bool              `json:"hasZ"`
+		HasM             bool              `json:"hasM"`
+		Rings            [][][]*float64    `json:"rings"`
+		SpatialReference *SpatialReference `json:"spatialReference"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	rings, err := newParts(raw.Rings, raw.HasZ, raw.HasM)
+	if err != nil {
+		return fmt.Errorf("failed to decode rings: %w", err)
+	}
+	*g = GeometryPolygon{HasZ: raw.HasZ, HasM: raw.HasM, Rings: rings, SpatialReference: raw.SpatialReference}
+	return nil
+}
+
+type GeometryEnvelope struct {
+	XMin float64 `json:"xmin"`
+	YMin float64 `json:"ymin"`
+	XMax float64 `json:"xmax"`
+	YMax float64 `json:"ymax"`
+	// Only set when the envelope has z values
+	ZMin *float64 `json:"zmin,omitempty"`
+	ZMax *float64 `json:"zmax,omitempty"`
+	// Only set when the envelope has m values
+	MMin             *float64          `json:"mmin,omitempty"`
+	MMax             *float64          `json:"mmax,omitempty"`
+	SpatialReference *SpatialReference `json:"spatialReference,omitempty"`
+}
+
+// Returns the geometry type of one of the geometry types
+func geometryTypeOf(geometry interface{}) (string, error) {
+	switch geometry.(type) {
+	case GeometryPoint, *GeometryPoint:
+		return GeometryTypePoint, nil
+	case GeometryMultiPoint, *GeometryMultiPoint:
+		return GeometryTypeMultiPoint, nil
+	case GeometryPolyline, *GeometryPolyline:
+		return GeometryTypePolyline, nil
+	case GeometryPolygon, *GeometryPolygon:
+		return GeometryTypePolygon, nil
+	case GeometryEnvelope, *GeometryEnvelope:
+		return GeometryTypeEnvelope, nil
+	default:
+		return GeometryTypeNone, fmt.Errorf("unhandled geometry: %T", geometry)
+	}
+}
+
+// Decodes a geometry as returned in a query response in to its geometry type.
+// Geometries in a query response usually don't carry their own hasZ and hasM
+// flags or spatial reference so the ones from the response are used unless the
+// geometry has them.
+func decodeGeometry(geometryType string, hasZ bool, hasM bool, sr *SpatialReference, v interface{}) (interface{}, error) {
+	if raw, ok := v.(map[string]interface{}); ok {
+		if z, ok := raw["hasZ"].(bool); ok {
+			hasZ = z
+		}
+		if m, ok := raw["hasM"].(bool); ok {
+			hasM = m
+		}
+		linearized, err := linearizeCurves(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to densify curves: %w", err)
+		}
+		v = linearized
+	}
+
+	coordinateType := reflect.TypeOf(Coordinate{})
+
+	decode := func(output interface{}) error {
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook: func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+				if to != coordinateType {
+					return data, nil
+				}
+				raw, ok := data.([]interface{})
+				if !ok {
+					return data, nil
+				}
+				values := make([]*float64, len(raw))
+				for i, r := range raw {
+					if r == nil {
+						continue
+					}
+					value, ok := r.(float64)
+					if !ok {
+						return nil, fmt.Errorf("coordinate value must be a number but got %T", r)
+					}
+					values[i] = &value
+				}
+				return newCoordinate(values, hasZ, hasM)
+			},
+			Result: output,
+		})
+		if err != nil {
+			return err
+		}
+		return decoder.Decode(v)
+	}
+
+	switch geometryType {
+	case GeometryTypePoint:
+		geometry := GeometryPoint{SpatialReference: sr}
+		if err := decode(&geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode point geometry: %w", err)
+		}
+		return geometry, nil
+	case GeometryTypeMultiPoint:
+		geometry := GeometryMultiPoint{HasZ: hasZ, HasM: hasM, SpatialReference: sr}
+		if err := decode(&geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode multipoint geometry: %w", err)
+		}
+		return geometry, nil
+	case GeometryTypePolyline:
+		geometry := GeometryPolyline{HasZ: hasZ, HasM: hasM, SpatialReference: sr}
+		if err := decode(&geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode polyline geometry: %w", err)
+		}
+		return geometry, nil
+	case GeometryTypePolygon:
+		geometry := GeometryPolygon{HasZ: hasZ, HasM: hasM, SpatialReference: sr}
+		if err := decode(&geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode polygon geometry: %w", err)
+		}
+		return geometry, nil
+	case GeometryTypeEnvelope:
+		geometry := GeometryEnvelope{SpatialReference: sr}
+		if err := decode(&geometry); err != nil {
+			return nil, fmt.Errorf("failed to decode envelope geometry: %w", err)
+		}
+		return geometry, nil
+	default:
+		return nil, fmt.Errorf("unhandled geometry type: %s", geometryType)
+	}
+}
+
+// Returns the type of a geometry from its members, for responses that don't
+// state the geometry type
+func inferGeometryType(raw map[string]interface{}) string {
+	has := func(key string) bool {
+		_, ok := raw[key]
+		return ok
+	}
+
+	switch {
+	case has("x"):
+		return GeometryTypePoint
+	case has("points"):
+		return GeometryTypeMultiPoint
+	case has("paths") || has("curvePaths"):
+		return GeometryTypePolyline
+	case has("rings") || has("curveRings"):
+		return GeometryTypePolygon
+	case has("xmin"):
+		return GeometryTypeEnvelope
+	default:
+		return GeometryTypeNone
+	}
+}
+
+// Returns whether the coordinates of a geometry have z and m values from the
+// number of values of its first coordinate, for responses that don't have
+// hasZ and hasM. A third value is taken to be z as geometries with m values
+// but no z values can't be told apart from geometries with z values.
+func inferCoordinateDimensions(raw map[string]interface{}) (hasZ bool, hasM bool) {
+	var first func(v interface{}) []interface{}
+	first = func(v interface{}) []interface{} {
+		values, ok := v.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil
+		}
+		if _, ok := values[0].(float64); ok {
+			return values
+		}
+		for _, value := range values {
+			if coordinate := first(value); coordinate != nil {
+				return coordinate
+			}
+		}
+		return nil
+	}
+
+	for _, key := range []string{"points", "paths", "curvePaths", "rings", "curveRings"} {
+		if coordinate := first(raw[key]); coordinate != nil {
+			return len(coordinate) > 2, len(coordinate) > 3
+		}
+	}
+	return false, false
 }
@@ -0,0 +1,175 @@
+package featureserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestDecodeGeometry(t *testing.T) {
+	t.Run("Z and M values", func(t *testing.T) {
+		type GeometryTest struct {
+			Name         string
+			GeometryType string
+			HasZ         bool
+			HasM         bool
+			JSON         string
+			ExpectZ      *float64
+			ExpectM      *float64
+		}
+
+		z, m := 10.0, 20.0
+
+		geometryTests := []GeometryTest{
+			{
+				Name:         "xy",
+				GeometryType: GeometryTypeMultiPoint,
+				JSON:         `{"points":[[1,2]]}`,
+			},
+			{
+				Name:         "xyz",
+				GeometryType: GeometryTypeMultiPoint,
+				HasZ:         true,
+				JSON:         `{"points":[[1,2,10]]}`,
+				ExpectZ:      &z,
+			},
+			{
+				Name:         "xym",
+				GeometryType: GeometryTypeMultiPoint,
+				HasM:         true,
+				JSON:         `{"points":[[1,2,20]]}`,
+				ExpectM:      &m,
+			},
+			{
+				Name:         "xyzm",
+				GeometryType: GeometryTypeMultiPoint,
+				HasZ:         true,
+				HasM:         true,
+				JSON:         `{"points":[[1,2,10,20]]}`,
+				ExpectZ:      &z,
+				ExpectM:      &m,
+			},
+			{
+				Name:         "xym from geometry flags",
+				GeometryType: GeometryTypePolyline,
+				JSON:         `{"hasM":true,"paths":[[[1,2,20],[3,4,20]]]}`,
+				ExpectM:      &m,
+			},
+			{
+				Name:         "xyz polygon",
+				GeometryType: GeometryTypePolygon,
+				HasZ:         true,
+				JSON:         `{"rings":[[[1,2,10],[3,4,10],[3,2,10],[1,2,10]]]}`,
+				ExpectZ:      &z,
+			},
+		}
+
+		for _, geometryTest := range geometryTests {
+			var raw interface{}
+			if err := json.Unmarshal([]byte(geometryTest.JSON), &raw); err != nil {
+				t.Fatalf("%s: failed to unmarshal geometry json: %v", geometryTest.Name, err)
+			}
+
+			geometry, err := decodeGeometry(geometryTest.GeometryType, geometryTest.HasZ, geometryTest.HasM, nil, raw)
+			if err != nil {
+				t.Fatalf("%s: failed to decode geometry: %v", geometryTest.Name, err)
+			}
+
+			var c Coordinate
+			switch g := geometry.(type) {
+			case GeometryMultiPoint:
+				c = g.Points[0]
+			case GeometryPolyline:
+				c = g.Paths[0][0]
+			case GeometryPolygon:
+				c = g.Rings[0][0]
+			default:
+				t.Fatalf("%s: unhandled geometry type: %T", geometryTest.Name, g)
+			}
+
+			if c.X != 1 || c.Y != 2 {
+				t.Errorf("%s: expected x 1 and y 2, got: %f, %f", geometryTest.Name, c.X, c.Y)
+			}
+
+			if (c.Z == nil) != (geometryTest.ExpectZ == nil) || (c.Z != nil && *c.Z != *geometryTest.ExpectZ) {
+				t.Errorf("%s: expected z %v, got: %v", geometryTest.Name, fmtFloat(geometryTest.ExpectZ), fmtFloat(c.Z))
+			}
+
+			if (c.M == nil) != (geometryTest.ExpectM == nil) || (c.M != nil && *c.M != *geometryTest.ExpectM) {
+				t.Errorf("%s: expected m %v, got: %v", geometryTest.Name, fmtFloat(geometryTest.ExpectM), fmtFloat(c.M))
+			}
+		}
+	})
+
+	t.Run("Coordinate json", func(t *testing.T) {
+		z, m := 3.0, 4.0
+		coordinates := []Coordinate{
+			{X: 1, Y: 2},
+			{X: 1, Y: 2, Z: &z},
+			{X: 1, Y: 2, Z: &z, M: &m},
+		}
+		expect := []string{"[1,2]", "[1,2,3]", "[1,2,3,4]"}
+
+		for i, c := range coordinates {
+			b, err := json.Marshal(c)
+			if err != nil {
+				t.Fatalf("failed to marshal coordinate: %v", err)
+			}
+			if string(b) != expect[i] {
+				t.Errorf("expected %s, got: %s", expect[i], b)
+			}
+
+			var decoded Coordinate
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("failed to unmarshal coordinate: %v", err)
+			}
+			if fmtFloat(decoded.Z) != fmtFloat(c.Z) || fmtFloat(decoded.M) != fmtFloat(c.M) {
+				t.Errorf("expected %s to round trip, got: %+v", expect[i], decoded)
+			}
+		}
+	})
+
+	t.Run("Geometry json uses hasZ and hasM", func(t *testing.T) {
+		var polyline GeometryPolyline
+		if err := json.Unmarshal([]byte(`{"hasM":true,"paths":[[[1,2,3],[4,5,6]]]}`), &polyline); err != nil {
+			t.Fatalf("failed to unmarshal polyline: %v", err)
+		}
+		var polygon GeometryPolygon
+		if err := json.Unmarshal([]byte(`{"hasM":true,"rings":[[[0,0,1],[0,1,2],[1,1,3],[0,0,1]]],"spatialReference":{"wkid":4326}}`), &polygon); err != nil {
+			t.Fatalf("failed to unmarshal polygon: %v", err)
+		}
+		var multiPoint GeometryMultiPoint
+		if err := json.Unmarshal([]byte(`{"hasZ":true,"hasM":true,"points":[[1,2,3,4],[5,6,7]]}`), &multiPoint); err != nil {
+			t.Fatalf("failed to unmarshal multipoint: %v", err)
+		}
+
+		if c := polyline.Paths[0][1]; !polyline.HasM || c.Z != nil || fmtFloat(c.M) != "6" {
+			t.Errorf("expected m value 6 and no z value, got: %+v", c)
+		}
+		if c := polygon.Rings[0][2]; !polygon.HasM || c.Z != nil || fmtFloat(c.M) != "3" {
+			t.Errorf("expected m value 3 and no z value, got: %+v", c)
+		}
+		if polygon.SpatialReference == nil || polygon.SpatialReference.WKID != 4326 {
+			t.Errorf("expected spatial reference 4326, got: %v", polygon.SpatialReference)
+		}
+		if c := multiPoint.Points[0]; fmtFloat(c.Z) != "3" || fmtFloat(c.M) != "4" {
+			t.Errorf("expected z value 3 and m value 4, got: %+v", c)
+		}
+		if c := multiPoint.Points[1]; fmtFloat(c.Z) != "7" || c.M != nil {
+			t.Errorf("expected z value 7 and no m value, got: %+v", c)
+		}
+
+		// An m only geometry is accepted by an m enabled layer
+		info := FeatureLayerInfo{GeometryType: GeometryTypePolyline, HasM: true}
+		if err := ValidateFeature(TypedFeature[struct{}, GeometryPolyline]{Geometry: polyline}, info); err != nil {
+			t.Errorf("expected m only polyline to be valid, got: %v", err)
+		}
+	})
+}
+
+func fmtFloat(f *float64) string {
+	if f == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", *f)
+}
@@ -36,6 +36,44 @@ type FieldInfo struct {
 	Nullable bool   `json:"nullable"`
 }
 
+const (
+	RelationshipCardinalityOneToOne   = "esriRelCardinalityOneToOne"
+	RelationshipCardinalityOneToMany  = "esriRelCardinalityOneToMany"
+	RelationshipCardinalityManyToMany = "esriRelCardinalityManyToMany"
+)
+
+const (
+	// The layer is the origin of the relationship
+	RelationshipRoleOrigin = "esriRelRoleOrigin"
+	// The layer is the destination of the relationship
+	RelationshipRoleDestination = "esriRelRoleDestination"
+)
+
+type Relationship struct {
+	// Used as the relationship id of related records queries
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// The layer or table on the other side of the relationship
+	RelatedTableID LayerID `json:"relatedTableId"`
+	// Can be one of:
+	//  - RelationshipCardinalityOneToOne
+	//  - RelationshipCardinalityOneToMany
+	//  - RelationshipCardinalityManyToMany
+	Cardinality string `json:"cardinality"`
+	// Can be one of:
+	//  - RelationshipRoleOrigin
+	//  - RelationshipRoleDestination
+	Role string `json:"role"`
+	// Name of the field of the layer that relates it to the other side
+	KeyField string `json:"keyField"`
+	// If true, deleting an origin feature deletes its related features
+	Composite bool `json:"composite"`
+	// Only set for many to many relationships, which are stored in their own table
+	RelationshipTableID *LayerID `json:"relationshipTableId"`
+	// Only set for many to many relationships
+	KeyFieldInRelationshipTable string `json:"keyFieldInRelationshipTable"`
+}
+
 type FeatureLayerInfo struct {
 	ID             LayerID `json:"id"`
 	CurrentVersion float32 `json:"currentVersion"`
@@ -45,8 +83,28 @@ type FeatureLayerInfo struct {
 	// Can be one of:
 	//  - GeometryTypePoint
 	//  - GeometryTypeMultiPoint
+	//  - GeometryTypePolyline
+	//  - GeometryTypePolygon
+	//  - GeometryTypeEnvelope
 	GeometryType string `json:"geometryType"`
-	Fields       []FieldInfo
+	// Name of the object id field
+	ObjectIDField string `json:"objectIdField"`
+	// Maximum number of features returned by a single query
+	MaxRecordCount int `json:"maxRecordCount"`
+	// If true, the geometries of the layer have z values
+	HasZ bool `json:"hasZ"`
+	// If true, the geometries of the layer have m values
+	HasM   bool `json:"hasM"`
+	Fields []FieldInfo
+	// Extent of all the features in the layer
+	Extent GeometryEnvelope `json:"extent"`
+	// Spatial reference of the layer, taken from the extent when the layer
+	// doesn't report one on its own
+	SpatialReference *SpatialReference `json:"spatialReference"`
+	// Only set when the layer is time aware
+	TimeInfo *TimeInfo `json:"timeInfo"`
+	// The relationship classes the layer takes part in
+	Relationships []Relationship `json:"relationships"`
 }
 
 type TableInfo struct {
@@ -54,8 +112,16 @@ type TableInfo struct {
 	CurrentVersion float32 `json:"currentVersion"`
 	Name           string  `json:"name"`
 	// Should be LayerTypeTable
-	Type   string `json:"type"`
-	Fields []FieldInfo
+	Type string `json:"type"`
+	// Name of the object id field
+	ObjectIDField string `json:"objectIdField"`
+	// Maximum number of records returned by a single query
+	MaxRecordCount int `json:"maxRecordCount"`
+	Fields         []FieldInfo
+	// Only set when the table is time aware
+	TimeInfo *TimeInfo `json:"timeInfo"`
+	// The relationship classes the table takes part in
+	Relationships []Relationship `json:"relationships"`
 }
 
 func (l *Layer) Info(ctx context.Context) (info Info, err error) {
@@ -122,13 +188,16 @@ func (l *Layer) Info(ctx context.Context) (info Info, err error) {
 	switch layerType {
 	case LayerTypeFeatureLayer:
 		var info FeatureLayerInfo
-		if err := mapstructure.Decode(respJSON, &info); err != nil {
+		if err := decodeInfo(respJSON, &info); err != nil {
 			return info, fmt.Errorf("failed to decode feature layer info: %w", err)
 		}
+		if info.SpatialReference == nil {
+			info.SpatialReference = info.Extent.SpatialReference
+		}
 		return info, nil
 	case LayerTypeTable:
 		var info TableInfo
-		if err := mapstructure.Decode(respJSON, &info); err != nil {
+		if err := decodeInfo(respJSON, &info); err != nil {
 			return info, fmt.Errorf("failed to decode table info: %w", err)
 		}
 		return info, nil
@@ -136,3 +205,41 @@ func (l *Layer) Info(ctx context.Context) (info Info, err error) {
 		return info, fmt.Errorf("unhandled layer type: %s", layerType)
 	}
 }
+
+func decodeInfo(input interface{}, output interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: timeExtentDecodeHook,
+		Result:     output,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// Returns the name of the object id field of a layer
+func objectIDField(info Info) (string, error) {
+	var objectIDField string
+	var fields []FieldInfo
+
+	switch info := info.(type) {
+	case FeatureLayerInfo:
+		objectIDField, fields = info.ObjectIDField, info.Fields
+	case TableInfo:
+		objectIDField, fields = info.ObjectIDField, info.Fields
+	default:
+		return "", fmt.Errorf("unhandled info type: %T", info)
+	}
+
+	if objectIDField != "" {
+		return objectIDField, nil
+	}
+
+	for _, field := range fields {
+		if field.Type == FieldTypeOID {
+			return field.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("missing object id field")
+}
@@ -2,7 +2,9 @@ package featureserver
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -127,6 +129,9 @@ func TestLayerInfo(t *testing.T) {
 					if info.CurrentVersion != layerTest.CurrentVersion {
 						t.Errorf("expected layer current version %f, got: %f", layerTest.CurrentVersion, info.CurrentVersion)
 					}
+					if info.Extent.XMin > info.Extent.XMax || info.Extent.YMin > info.Extent.YMax {
+						t.Errorf("expected valid layer extent, got: %+v", info.Extent)
+					}
 				case TableInfo:
 					if layerTest.Type != LayerTypeTable {
 						t.Errorf("expected layer type %s, got: %s", LayerTypeTable, layerTest.Type)
@@ -145,3 +150,60 @@ func TestLayerInfo(t *testing.T) {
 	})
 
 }
+
+func TestLayerInfoExtent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0":
+			fmt.Fprint(w, `{"id":0,"name":"points","type":"Feature Layer","geometryType":"esriGeometryPoint","extent":{"xmin":-1,"ymin":-2,"xmax":3,"ymax":4,"spatialReference":{"wkid":102100,"latestWkid":3857}}}`)
+		case "/1":
+			fmt.Fprint(w, `{"id":1,"name":"lines","type":"Feature Layer","geometryType":"esriGeometryPolyline","spatialReference":{"wkid":4326},"extent":{"xmin":1,"ymin":2,"xmax":3,"ymax":4,"zmin":5,"zmax":6,"spatialReference":{"wkid":102100}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	layerInfo := func(t *testing.T, layerID LayerID) FeatureLayerInfo {
+		info, err := fsc.Layer(layerID).Info(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get layer info: %v", err)
+		}
+		flInfo, ok := info.(FeatureLayerInfo)
+		if !ok {
+			t.Fatalf("expected FeatureLayerInfo, got: %T", info)
+		}
+		return flInfo
+	}
+
+	t.Run("Spatial reference from extent", func(t *testing.T) {
+		info := layerInfo(t, 0)
+
+		extent := info.Extent
+		if extent.XMin != -1 || extent.YMin != -2 || extent.XMax != 3 || extent.YMax != 4 || extent.ZMin != nil {
+			t.Errorf("expected extent -1,-2,3,4, got: %+v", extent)
+		}
+		if info.SpatialReference == nil || info.SpatialReference.WKID != 102100 || info.SpatialReference.LatestWKID != 3857 {
+			t.Errorf("expected the spatial reference of the extent, got: %v", info.SpatialReference)
+		}
+	})
+
+	t.Run("Layer spatial reference", func(t *testing.T) {
+		info := layerInfo(t, 1)
+
+		if fmtFloat(info.Extent.ZMin) != "5" || fmtFloat(info.Extent.ZMax) != "6" {
+			t.Errorf("expected extent z values 5 and 6, got: %v and %v", fmtFloat(info.Extent.ZMin), fmtFloat(info.Extent.ZMax))
+		}
+		if info.Extent.SpatialReference == nil || info.Extent.SpatialReference.WKID != 102100 {
+			t.Errorf("expected extent spatial reference 102100, got: %v", info.Extent.SpatialReference)
+		}
+		if info.SpatialReference == nil || info.SpatialReference.WKID != 4326 {
+			t.Errorf("expected layer spatial reference 4326, got: %v", info.SpatialReference)
+		}
+	})
+}
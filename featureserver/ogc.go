@@ -0,0 +1,160 @@
+package featureserver
+
+import "fmt"
+
+// OGC simple feature geometry types, shared by the WKT and WKB encodings
+const (
+	ogcPoint              = 1
+	ogcLineString         = 2
+	ogcPolygon            = 3
+	ogcMultiPoint         = 4
+	ogcMultiLineString    = 5
+	ogcMultiPolygon       = 6
+	ogcGeometryCollection = 7
+)
+
+var ogcNames = map[int]string{
+	ogcPoint:              "POINT",
+	ogcLineString:         "LINESTRING",
+	ogcPolygon:            "POLYGON",
+	ogcMultiPoint:         "MULTIPOINT",
+	ogcMultiLineString:    "MULTILINESTRING",
+	ogcMultiPolygon:       "MULTIPOLYGON",
+	ogcGeometryCollection: "GEOMETRYCOLLECTION",
+}
+
+// A geometry in the OGC model. Only the member matching the kind is set:
+//   - ogcPoint uses points with zero or one coordinate
+//   - ogcLineString and ogcMultiPoint use points
+//   - ogcPolygon and ogcMultiLineString use lines
+//   - ogcMultiPolygon uses polygons
+//   - ogcGeometryCollection is only supported empty
+type ogcGeometry struct {
+	kind     int
+	hasZ     bool
+	hasM     bool
+	points   []Coordinate
+	lines    [][]Coordinate
+	polygons [][][]Coordinate
+	// Spatial reference id, zero when unknown
+	srid int
+}
+
+// Converts one of the geometry types to the OGC model. Polygon rings are
+// grouped in to polygons with the exterior rings counterclockwise and the
+// holes clockwise as OGC expects them.
+func toOGC(geometry interface{}) (g ogcGeometry, err error) {
+	var sr *SpatialReference
+
+	switch geometry := geometry.(type) {
+	case nil, GeometryNone:
+		g.kind = ogcGeometryCollection
+		return g, nil
+	case GeometryPoint:
+		g.kind = ogcPoint
+		g.hasZ, g.hasM = geometry.Z != nil, geometry.M != nil
+		g.points = []Coordinate{{X: geometry.X, Y: geometry.Y, Z: geometry.Z, M: geometry.M}}
+		sr = geometry.SpatialReference
+	case GeometryMultiPoint:
+		g.kind = ogcMultiPoint
+		g.hasZ, g.hasM, _ = geometryDimensions(geometry)
+		g.points = geometry.Points
+		sr = geometry.SpatialReference
+	case GeometryPolyline:
+		g.hasZ, g.hasM, _ = geometryDimensions(geometry)
+		// A polyline without paths stays a line string so it keeps its type
+		switch len(geometry.Paths) {
+		case 0:
+			g.kind = ogcLineString
+		case 1:
+			g.kind = ogcLineString
+			g.points = geometry.Paths[0]
+		default:
+			g.kind = ogcMultiLineString
+			g.lines = geometry.Paths
+		}
+		sr = geometry.SpatialReference
+	case GeometryPolygon:
+		g.hasZ, g.hasM, _ = geometryDimensions(geometry)
+		polygons := polygonsFromRings(geometry.Rings)
+		for i, polygon := range polygons {
+			for j, ring := range polygon {
+				polygons[i][j] = reverseRing(ring)
+			}
+		}
+		// A polygon without rings stays a polygon so it keeps its type
+		switch len(polygons) {
+		case 0:
+			g.kind = ogcPolygon
+		case 1:
+			g.kind = ogcPolygon
+			g.lines = polygons[0]
+		default:
+			g.kind = ogcMultiPolygon
+			g.polygons = polygons
+		}
+		sr = geometry.SpatialReference
+	case GeometryEnvelope:
+		g.kind = ogcPolygon
+		g.lines = [][]Coordinate{{
+			{X: geometry.XMin, Y: geometry.YMin},
+			{X: geometry.XMax, Y: geometry.YMin},
+			{X: geometry.XMax, Y: geometry.YMax},
+			{X: geometry.XMin, Y: geometry.YMax},
+			{X: geometry.XMin, Y: geometry.YMin},
+		}}
+		sr = geometry.SpatialReference
+	default:
+		return g, fmt.Errorf("unhandled geometry: %T", geometry)
+	}
+
+	if sr != nil {
+		g.srid = sr.LatestWKID
+		if g.srid == 0 {
+			g.srid = sr.WKID
+		}
+	}
+
+	return g, nil
+}
+
+// Converts a geometry in the OGC model to one of the geometry types. Polygon
+// rings are oriented the way Esri expects them, exterior rings clockwise and
+// holes counterclockwise.
+func fromOGC(g ogcGeometry) (interface{}, error) {
+	var sr *SpatialReference
+	if g.srid != 0 {
+		sr = &SpatialReference{WKID: g.srid}
+	}
+
+	switch g.kind {
+	case ogcPoint:
+		if len(g.points) == 0 {
+			return GeometryNone{}, nil
+		}
+		c := g.points[0]
+		return GeometryPoint{X: c.X, Y: c.Y, Z: c.Z, M: c.M, SpatialReference: sr}, nil
+	case ogcMultiPoint:
+		return GeometryMultiPoint{HasZ: g.hasZ, HasM: g.hasM, Points: g.points, SpatialReference: sr}, nil
+	case ogcLineString:
+		var paths [][]Coordinate
+		if len(g.points) > 0 {
+			paths = [][]Coordinate{g.points}
+		}
+		return GeometryPolyline{HasZ: g.hasZ, HasM: g.hasM, Paths: paths, SpatialReference: sr}, nil
+	case ogcMultiLineString:
+		return GeometryPolyline{HasZ: g.hasZ, HasM: g.hasM, Paths: g.lines, SpatialReference: sr}, nil
+	case ogcPolygon:
+		return GeometryPolygon{HasZ: g.hasZ, HasM: g.hasM, Rings: orientPolygonRings(g.lines), SpatialReference: sr}, nil
+	case ogcMultiPolygon:
+		var rings [][]Coordinate
+		for _, polygon := range g.polygons {
+			rings = append(rings, orientPolygonRings(polygon)...)
+		}
+		return GeometryPolygon{HasZ: g.hasZ, HasM: g.hasM, Rings: rings, SpatialReference: sr}, nil
+	case ogcGeometryCollection:
+		return GeometryNone{}, nil
+	default:
+		return nil, fmt.Errorf("unhandled ogc geometry type: %d", g.kind)
+	}
+}
@@ -0,0 +1,158 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Iterates over all the features matching a query, one page at a time.
+//
+//	it := layer.QueryAll(ctx, variables)
+//	for it.Next() {
+//		f := it.Feature()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type QueryIterator struct {
+	ctx       context.Context
+	layer     *Layer
+	variables QueryVariables
+	offset    int
+	results   QueryResults
+	// Identifies the features of the current page, see pageKey
+	page    string
+	index   int
+	started bool
+	done    bool
+	err     error
+}
+
+// Pages through all the features matching the query using resultOffset and
+// resultRecordCount until the server stops reporting exceededTransferLimit.
+// ResultRecordCount is used as the page size, if it isn't greater than zero
+// the page size is the server's maxRecordCount. If no OrderByFields are given
+// the results are ordered by the object id field so the pages are stable.
+// The iteration stops with an error if the server reports more features but
+// returns an empty page, or returns the same page twice because it ignores
+// resultOffset.
+func (l *Layer) QueryAll(ctx context.Context, variables QueryVariables) *QueryIterator {
+	return &QueryIterator{
+		ctx:       ctx,
+		layer:     l,
+		variables: variables,
+		offset:    variables.ResultOffset,
+		index:     -1,
+	}
+}
+
+// Advances to the next feature, fetching the next page when needed. Returns
+// false when there are no more features or an error occurred.
+func (it *QueryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	for it.index >= len(it.results.Features) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	return true
+}
+
+// The current feature
+func (it *QueryIterator) Feature() Feature {
+	if it.index < 0 || it.index >= len(it.results.Features) {
+		return Feature{}
+	}
+	return it.results.Features[it.index]
+}
+
+// The results of the current page
+func (it *QueryIterator) Results() QueryResults {
+	return it.results
+}
+
+// The error that stopped the iteration, if any
+func (it *QueryIterator) Err() error {
+	return it.err
+}
+
+func (it *QueryIterator) fetch() error {
+	if !it.started {
+		it.started = true
+		if len(it.variables.OrderByFields) == 0 {
+			info, err := it.layer.Info(it.ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get layer info: %w", err)
+			}
+			oidField, err := objectIDField(info)
+			if err != nil {
+				return err
+			}
+			it.variables.OrderByFields = []OrderByField{{Field: oidField, Order: OrderAsc}}
+		}
+		if it.variables.ResultRecordCount <= 0 {
+			it.variables.ResultRecordCount = -1
+		}
+	}
+
+	variables := it.variables
+	variables.ResultOffset = it.offset
+
+	results, err := it.layer.Query(it.ctx, variables)
+	if err != nil {
+		return fmt.Errorf("failed to query page at offset %d: %w", it.offset, err)
+	}
+
+	if results.ExceededTransferLimit && len(results.Features) == 0 {
+		return fmt.Errorf("page at offset %d exceeded the transfer limit but has no features", it.offset)
+	}
+
+	page := pageKey(results)
+	if it.page != "" && page == it.page {
+		return fmt.Errorf("page at offset %d has the same features as the previous page, the server may not support resultOffset", it.offset)
+	}
+
+	it.results = results
+	it.page = page
+	it.index = 0
+	it.offset += len(results.Features)
+	it.done = !results.ExceededTransferLimit
+
+	return nil
+}
+
+// Returns the object ids of the features of a page, or their attributes if
+// the object id field isn't known, so repeated pages can be detected
+func pageKey(results QueryResults) string {
+	var key strings.Builder
+	for _, f := range results.Features {
+		var attributes map[string]interface{}
+		if results.ObjectIDFieldName != "" {
+			json.Unmarshal(f.Attributes, &attributes)
+		}
+		found := false
+		for name, value := range attributes {
+			if strings.EqualFold(name, results.ObjectIDFieldName) {
+				fmt.Fprintf(&key, "%v\n", value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			key.Write(f.Attributes)
+			key.WriteByte('\n')
+		}
+	}
+	return key.String()
+}
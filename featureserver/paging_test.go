@@ -0,0 +1,152 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestLayerQueryAll(t *testing.T) {
+	t.Run("Pages until transfer limit is not exceeded", func(t *testing.T) {
+		const totalFeatures = 25
+		const maxRecordCount = 10
+
+		var offsets []int
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/0":
+				fmt.Fprint(w, `{"id":0,"name":"points","type":"Feature Layer","objectIdField":"objectid","fields":[{"name":"objectid","type":"esriFieldTypeOID"}]}`)
+			case "/0/query":
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("failed to parse form: %v", err)
+					return
+				}
+				if orderBy := r.FormValue("orderByFields"); orderBy != "objectid ASC" {
+					t.Errorf("expected orderByFields 'objectid ASC', got: '%s'", orderBy)
+				}
+
+				offset, _ := strconv.Atoi(r.FormValue("resultOffset"))
+				offsets = append(offsets, offset)
+
+				var features []map[string]interface{}
+				for i := offset; i < totalFeatures && i < offset+maxRecordCount; i++ {
+					features = append(features, map[string]interface{}{
+						"attributes": map[string]interface{}{"objectid": i + 1},
+					})
+				}
+
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"objectIdFieldName":     "objectid",
+					"features":              features,
+					"exceededTransferLimit": offset+maxRecordCount < totalFeatures,
+				})
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+
+		fsc, err := NewClient(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to create feature server client: %v", err)
+		}
+
+		it := fsc.Layer(0).QueryAll(context.Background(), QueryVariables{Where: "1=1"})
+
+		var objectIDs []int
+		for it.Next() {
+			var attributes struct {
+				ObjectID int `json:"objectid"`
+			}
+			if err := json.Unmarshal(it.Feature().Attributes, &attributes); err != nil {
+				t.Fatalf("failed to unmarshal attributes: %v", err)
+			}
+			objectIDs = append(objectIDs, attributes.ObjectID)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("failed to query all: %v", err)
+		}
+
+		if len(objectIDs) != totalFeatures {
+			t.Fatalf("expected %d features, got: %d", totalFeatures, len(objectIDs))
+		}
+
+		for i, objectID := range objectIDs {
+			if objectID != i+1 {
+				t.Errorf("expected object id %d, got: %d", i+1, objectID)
+			}
+		}
+
+		expectOffsets := []int{0, 10, 20}
+		if fmt.Sprint(offsets) != fmt.Sprint(expectOffsets) {
+			t.Errorf("expected offsets %v, got: %v", expectOffsets, offsets)
+		}
+	})
+	t.Run("Stops when pages don't advance", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			features func(offset int) []map[string]interface{}
+		}{
+			{
+				name: "Offset ignored",
+				features: func(offset int) []map[string]interface{} {
+					return []map[string]interface{}{
+						{"attributes": map[string]interface{}{"OBJECTID": 1}},
+						{"attributes": map[string]interface{}{"OBJECTID": 2}},
+					}
+				},
+			},
+			{
+				name: "Empty page",
+				features: func(offset int) []map[string]interface{} {
+					if offset > 0 {
+						return nil
+					}
+					return []map[string]interface{}{
+						{"attributes": map[string]interface{}{"OBJECTID": 1}},
+					}
+				},
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				requests := 0
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if err := r.ParseMultipartForm(1 << 20); err != nil {
+						t.Errorf("failed to parse form: %v", err)
+						return
+					}
+					requests++
+					offset, _ := strconv.Atoi(r.FormValue("resultOffset"))
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"objectIdFieldName":     "objectid",
+						"features":              test.features(offset),
+						"exceededTransferLimit": true,
+					})
+				}))
+				defer srv.Close()
+
+				fsc, err := NewClient(srv.URL)
+				if err != nil {
+					t.Fatalf("failed to create feature server client: %v", err)
+				}
+
+				it := fsc.Layer(0).QueryAll(context.Background(), QueryVariables{Where: "1=1", OrderByFields: []OrderByField{{Field: "objectid", Order: OrderAsc}}})
+				for it.Next() {
+				}
+				if it.Err() == nil {
+					t.Errorf("expected error, got none")
+				}
+				if requests != 2 {
+					t.Errorf("expected 2 requests, got: %d", requests)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,695 @@
+package featureserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Decodes the protocol buffer wire format returned for f=pbf queries. The
+// messages follow the esriPBuffer.FeatureCollectionPBuffer schema, only the
+// parts needed to build QueryResults are read and everything else is skipped.
+
+const (
+	pbfWireVarint  = 0
+	pbfWireFixed64 = 1
+	pbfWireBytes   = 2
+	pbfWireFixed32 = 5
+)
+
+// FeatureCollectionPBuffer.GeometryType
+var pbfGeometryTypes = map[uint64]string{
+	0:   GeometryTypePoint,
+	1:   GeometryTypeMultiPoint,
+	2:   GeometryTypePolyline,
+	3:   GeometryTypePolygon,
+	127: GeometryTypeNone,
+}
+
+// FeatureCollectionPBuffer.FieldType
+var pbfFieldTypes = map[uint64]string{
+	0:  FieldTypeSmallInt,
+	1:  FieldTypeInt,
+	2:  "esriFieldTypeSingle",
+	3:  FieldTypeDouble,
+	4:  FieldTypeString,
+	5:  FieldTypeDate,
+	6:  FieldTypeOID,
+	7:  "esriFieldTypeGeometry",
+	8:  "esriFieldTypeBlob",
+	9:  "esriFieldTypeRaster",
+	10: "esriFieldTypeGUID",
+	11: "esriFieldTypeGlobalID",
+	12: "esriFieldTypeXML",
+	13: "esriFieldTypeBigInteger",
+	14: "esriFieldTypeDateOnly",
+	15: "esriFieldTypeTimeOnly",
+	16: "esriFieldTypeTimestampOffset",
+}
+
+var errPBFTruncated = errors.New("truncated pbf message")
+
+type pbfReader struct {
+	b []byte
+}
+
+func (r *pbfReader) done() bool {
+	return len(r.b) == 0
+}
+
+func (r *pbfReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		return 0, errPBFTruncated
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *pbfReader) key() (field uint64, wireType uint64, err error) {
+	k, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return k >> 3, k & 7, nil
+}
+
+func (r *pbfReader) bytes() ([]byte, error) {
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.b)) {
+		return nil, errPBFTruncated
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b, nil
+}
+
+func (r *pbfReader) fixed64() (uint64, error) {
+	if len(r.b) < 8 {
+		return 0, errPBFTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v, nil
+}
+
+func (r *pbfReader) fixed32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, errPBFTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *pbfReader) double() (float64, error) {
+	v, err := r.fixed64()
+	return math.Float64frombits(v), err
+}
+
+func (r *pbfReader) skip(wireType uint64) error {
+	var err error
+	switch wireType {
+	case pbfWireVarint:
+		_, err = r.varint()
+	case pbfWireFixed64:
+		_, err = r.fixed64()
+	case pbfWireBytes:
+		_, err = r.bytes()
+	case pbfWireFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unhandled pbf wire type: %d", wireType)
+	}
+	return err
+}
+
+// Reads a repeated varint field which can either be packed or a single value
+func (r *pbfReader) varints(wireType uint64, values []uint64) ([]uint64, error) {
+	if wireType == pbfWireVarint {
+		v, err := r.varint()
+		return append(values, v), err
+	}
+
+	b, err := r.bytes()
+	if err != nil {
+		return values, err
+	}
+	packed := pbfReader{b}
+	for !packed.done() {
+		v, err := packed.varint()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func zigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// FeatureCollectionPBuffer.Transform, used to turn the quantized integer
+// coordinates back in to real coordinates.
+type pbfTransform struct {
+	lowerLeft                                      bool
+	xScale, yScale, zScale, mScale                 float64
+	xTranslate, yTranslate, zTranslate, mTranslate float64
+}
+
+// Decodes a f=pbf response. Errors are returned as json even when asking for
+// pbf so those are decoded as json.
+func decodePBFResponse(r io.Reader, returnGeometry bool, fn func(f Feature) error) (results QueryResults, err error) {
+	respBody, err := io.ReadAll(r)
+	if err != nil {
+		return results, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if len(respBody) > 0 && respBody[0] == '{' {
+		return decodeQueryResults(bytes.NewReader(respBody), QueryVariables{ReturnGeometry: returnGeometry}, fn)
+	}
+
+	return decodePBFQueryResults(respBody, returnGeometry, fn)
+}
+
+func decodePBFQueryResults(b []byte, returnGeometry bool, fn func(f Feature) error) (results QueryResults, err error) {
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return results, fmt.Errorf("failed to decode pbf feature collection: %w", err)
+		}
+		if field != 2 {
+			if err := r.skip(wireType); err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature collection: %w", err)
+			}
+			continue
+		}
+
+		queryResult, err := r.bytes()
+		if err != nil {
+			return results, fmt.Errorf("failed to decode pbf query result: %w", err)
+		}
+
+		qr := pbfReader{queryResult}
+		for !qr.done() {
+			field, _, err := qr.key()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf query result: %w", err)
+			}
+			if field != 1 {
+				return results, fmt.Errorf("unhandled pbf query result: %d", field)
+			}
+			featureResult, err := qr.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature result: %w", err)
+			}
+			results, err = decodePBFFeatureResult(featureResult, returnGeometry, fn)
+			if err != nil {
+				return results, err
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func decodePBFFeatureResult(b []byte, returnGeometry bool, fn func(f Feature) error) (results QueryResults, err error) {
+	transform := pbfTransform{xScale: 1, yScale: 1, zScale: 1, mScale: 1}
+	// The features are decoded last since they need the fields and transform
+	var features [][]byte
+
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return results, fmt.Errorf("failed to decode pbf feature result: %w", err)
+		}
+
+		switch field {
+		case 1, 3:
+			v, err := r.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature result: %w", err)
+			}
+			if field == 1 {
+				results.ObjectIDFieldName = string(v)
+			} else {
+				results.GlobalIDFieldName = string(v)
+			}
+		case 7, 9, 10, 11:
+			v, err := r.varint()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature result: %w", err)
+			}
+			switch field {
+			case 7:
+				geometryType, ok := pbfGeometryTypes[v]
+				if !ok {
+					return results, fmt.Errorf("unhandled pbf geometry type: %d", v)
+				}
+				results.GeometryType = geometryType
+			case 9:
+				results.ExceededTransferLimit = v != 0
+			case 10:
+				results.HasZ = v != 0
+			case 11:
+				results.HasM = v != 0
+			}
+		case 8:
+			v, err := r.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf spatial reference: %w", err)
+			}
+			sr, err := decodePBFSpatialReference(v)
+			if err != nil {
+				return results, err
+			}
+			results.SpatialReference = &sr
+		case 12:
+			v, err := r.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf transform: %w", err)
+			}
+			if transform, err = decodePBFTransform(v); err != nil {
+				return results, err
+			}
+		case 13:
+			v, err := r.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf field: %w", err)
+			}
+			f, err := decodePBFField(v)
+			if err != nil {
+				return results, err
+			}
+			results.Fields = append(results.Fields, f)
+		case 15:
+			v, err := r.bytes()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature: %w", err)
+			}
+			features = append(features, v)
+		default:
+			if err := r.skip(wireType); err != nil {
+				return results, fmt.Errorf("failed to decode pbf feature result: %w", err)
+			}
+		}
+	}
+
+	for _, b := range features {
+		f, err := decodePBFFeature(b, results, transform, returnGeometry)
+		if err != nil {
+			return results, err
+		}
+		if err := fn(f); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func decodePBFSpatialReference(b []byte) (sr SpatialReference, err error) {
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return sr, fmt.Errorf("failed to decode pbf spatial reference: %w", err)
+		}
+		switch field {
+		case 1, 2, 3, 4:
+			v, err := r.varint()
+			if err != nil {
+				return sr, fmt.Errorf("failed to decode pbf spatial reference: %w", err)
+			}
+			switch field {
+			case 1:
+				sr.WKID = int(v)
+			case 2:
+				sr.LatestWKID = int(v)
+			case 3:
+				sr.VCSWKID = int(v)
+			case 4:
+				sr.LatestVCSWKID = int(v)
+			}
+		case 5:
+			v, err := r.bytes()
+			if err != nil {
+				return sr, fmt.Errorf("failed to decode pbf spatial reference: %w", err)
+			}
+			sr.WKT = string(v)
+		default:
+			if err := r.skip(wireType); err != nil {
+				return sr, fmt.Errorf("failed to decode pbf spatial reference: %w", err)
+			}
+		}
+	}
+	return sr, nil
+}
+
+func decodePBFTransform(b []byte) (t pbfTransform, err error) {
+	t = pbfTransform{xScale: 1, yScale: 1, zScale: 1, mScale: 1}
+
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+		}
+		switch field {
+		case 1:
+			v, err := r.varint()
+			if err != nil {
+				return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+			}
+			t.lowerLeft = v == 1
+		case 2, 3:
+			v, err := r.bytes()
+			if err != nil {
+				return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+			}
+			// Scale and Translate both have x, y, m and z doubles in that order
+			values := [4]*float64{&t.xScale, &t.yScale, &t.mScale, &t.zScale}
+			if field == 3 {
+				values = [4]*float64{&t.xTranslate, &t.yTranslate, &t.mTranslate, &t.zTranslate}
+			}
+			sr := pbfReader{v}
+			for !sr.done() {
+				field, wireType, err := sr.key()
+				if err != nil {
+					return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+				}
+				if field < 1 || field > 4 || wireType != pbfWireFixed64 {
+					if err := sr.skip(wireType); err != nil {
+						return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+					}
+					continue
+				}
+				if *values[field-1], err = sr.double(); err != nil {
+					return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+				}
+			}
+		default:
+			if err := r.skip(wireType); err != nil {
+				return t, fmt.Errorf("failed to decode pbf transform: %w", err)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+func decodePBFField(b []byte) (f Field, err error) {
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return f, fmt.Errorf("failed to decode pbf field: %w", err)
+		}
+		switch field {
+		case 1, 3:
+			v, err := r.bytes()
+			if err != nil {
+				return f, fmt.Errorf("failed to decode pbf field: %w", err)
+			}
+			if field == 1 {
+				f.Name = string(v)
+			} else {
+				f.Alias = string(v)
+			}
+		case 2:
+			v, err := r.varint()
+			if err != nil {
+				return f, fmt.Errorf("failed to decode pbf field: %w", err)
+			}
+			fieldType, ok := pbfFieldTypes[v]
+			if !ok {
+				return f, fmt.Errorf("unhandled pbf field type: %d", v)
+			}
+			f.Type = fieldType
+		default:
+			if err := r.skip(wireType); err != nil {
+				return f, fmt.Errorf("failed to decode pbf field: %w", err)
+			}
+		}
+	}
+	return f, nil
+}
+
+func decodePBFFeature(b []byte, results QueryResults, transform pbfTransform, returnGeometry bool) (f Feature, err error) {
+	var values []interface{}
+	var geometry []byte
+
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return f, fmt.Errorf("failed to decode pbf feature: %w", err)
+		}
+		switch field {
+		case 1:
+			v, err := r.bytes()
+			if err != nil {
+				return f, fmt.Errorf("failed to decode pbf feature: %w", err)
+			}
+			value, err := decodePBFValue(v)
+			if err != nil {
+				return f, err
+			}
+			values = append(values, value)
+		case 2:
+			if geometry, err = r.bytes(); err != nil {
+				return f, fmt.Errorf("failed to decode pbf feature: %w", err)
+			}
+		case 3:
+			return f, fmt.Errorf("unhandled pbf shape buffer geometry")
+		default:
+			if err := r.skip(wireType); err != nil {
+				return f, fmt.Errorf("failed to decode pbf feature: %w", err)
+			}
+		}
+	}
+
+	if len(values) > len(results.Fields) {
+		return f, fmt.Errorf("pbf feature has %d attributes but there are %d fields", len(values), len(results.Fields))
+	}
+
+	// Write the attributes in field order the same way a json response would
+	attributes := &bytes.Buffer{}
+	attributes.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			attributes.WriteByte(',')
+		}
+		name, err := json.Marshal(results.Fields[i].Name)
+		if err != nil {
+			return f, fmt.Errorf("failed to marshal attribute name: %w", err)
+		}
+		attributes.Write(name)
+		attributes.WriteByte(':')
+		v, err := json.Marshal(value)
+		if err != nil {
+			return f, fmt.Errorf("failed to marshal attribute '%s': %w", results.Fields[i].Name, err)
+		}
+		attributes.Write(v)
+	}
+	attributes.WriteByte('}')
+	f.Attributes = attributes.Bytes()
+
+	if !returnGeometry {
+		f.Geometry = GeometryNone{}
+		return f, nil
+	}
+
+	f.Geometry, err = decodePBFGeometry(geometry, results, transform)
+	if err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+func decodePBFValue(b []byte) (interface{}, error) {
+	r := pbfReader{b}
+	var value interface{}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+		}
+		switch field {
+		case 1:
+			v, err := r.bytes()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+			}
+			value = string(v)
+		case 2:
+			v, err := r.fixed32()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+			}
+			value = math.Float32frombits(v)
+		case 3:
+			v, err := r.double()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+			}
+			value = v
+		case 4, 5, 6, 7, 8, 9:
+			v, err := r.varint()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+			}
+			switch field {
+			case 4:
+				value = int32(zigzag(v))
+			case 5:
+				value = uint32(v)
+			case 6:
+				value = int64(v)
+			case 7:
+				value = v
+			case 8:
+				value = zigzag(v)
+			case 9:
+				value = v != 0
+			}
+		default:
+			if err := r.skip(wireType); err != nil {
+				return nil, fmt.Errorf("failed to decode pbf value: %w", err)
+			}
+		}
+	}
+	// A value without any of the fields set is null
+	return value, nil
+}
+
+func decodePBFGeometry(b []byte, results QueryResults, transform pbfTransform) (interface{}, error) {
+	var lengths []uint64
+	var coords []uint64
+
+	r := pbfReader{b}
+	for !r.done() {
+		field, wireType, err := r.key()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode pbf geometry: %w", err)
+		}
+		switch field {
+		case 2:
+			if lengths, err = r.varints(wireType, lengths); err != nil {
+				return nil, fmt.Errorf("failed to decode pbf geometry lengths: %w", err)
+			}
+		case 3:
+			if coords, err = r.varints(wireType, coords); err != nil {
+				return nil, fmt.Errorf("failed to decode pbf geometry coords: %w", err)
+			}
+		default:
+			if err := r.skip(wireType); err != nil {
+				return nil, fmt.Errorf("failed to decode pbf geometry: %w", err)
+			}
+		}
+	}
+
+	dimensions := 2
+	if results.HasZ {
+		dimensions++
+	}
+	if results.HasM {
+		dimensions++
+	}
+
+	if len(coords)%dimensions != 0 {
+		return nil, fmt.Errorf("pbf geometry has %d coords which is not a multiple of %d dimensions", len(coords), dimensions)
+	}
+
+	if len(lengths) == 0 && len(coords) > 0 {
+		lengths = []uint64{uint64(len(coords) / dimensions)}
+	}
+
+	// Coordinates are delta encoded from the previous vertex of the same part
+	var parts [][]Coordinate
+	offset := 0
+	for _, length := range lengths {
+		end := offset + int(length)*dimensions
+		if end > len(coords) {
+			return nil, fmt.Errorf("pbf geometry lengths exceed its coords")
+		}
+		part := make([]Coordinate, 0, length)
+		var previous [4]int64
+		for i := offset; i < end; i += dimensions {
+			var quantized [4]int64
+			for d := 0; d < dimensions; d++ {
+				previous[d] += zigzag(coords[i+d])
+				quantized[d] = previous[d]
+			}
+			part = append(part, transform.coordinate(quantized, results.HasZ, results.HasM))
+		}
+		parts = append(parts, part)
+		offset = end
+	}
+
+	switch results.GeometryType {
+	case GeometryTypePoint:
+		if len(parts) == 0 || len(parts[0]) == 0 {
+			return GeometryNone{}, nil
+		}
+		c := parts[0][0]
+		return GeometryPoint{X: c.X, Y: c.Y, Z: c.Z, M: c.M, SpatialReference: results.SpatialReference}, nil
+	case GeometryTypeMultiPoint:
+		var points []Coordinate
+		for _, part := range parts {
+			points = append(points, part...)
+		}
+		return GeometryMultiPoint{HasZ: results.HasZ, HasM: results.HasM, Points: points, SpatialReference: results.SpatialReference}, nil
+	case GeometryTypePolyline:
+		return GeometryPolyline{HasZ: results.HasZ, HasM: results.HasM, Paths: parts, SpatialReference: results.SpatialReference}, nil
+	case GeometryTypePolygon:
+		return GeometryPolygon{HasZ: results.HasZ, HasM: results.HasM, Rings: parts, SpatialReference: results.SpatialReference}, nil
+	case GeometryTypeNone:
+		return GeometryNone{}, nil
+	default:
+		return nil, fmt.Errorf("unhandled geometry type: %s", results.GeometryType)
+	}
+}
+
+func (t pbfTransform) coordinate(quantized [4]int64, hasZ bool, hasM bool) Coordinate {
+	c := Coordinate{
+		X: t.xTranslate + float64(quantized[0])*t.xScale,
+	}
+	if t.lowerLeft {
+		c.Y = t.yTranslate + float64(quantized[1])*t.yScale
+	} else {
+		c.Y = t.yTranslate - float64(quantized[1])*t.yScale
+	}
+
+	d := 2
+	if hasZ {
+		z := t.zTranslate + float64(quantized[d])*nonZero(t.zScale)
+		c.Z = &z
+		d++
+	}
+	if hasM {
+		m := t.mTranslate + float64(quantized[d])*nonZero(t.mScale)
+		c.M = &m
+	}
+
+	return c
+}
+
+// Servers leave the z and m scales at zero when they aren't quantized
+func nonZero(scale float64) float64 {
+	if scale == 0 {
+		return 1
+	}
+	return scale
+}
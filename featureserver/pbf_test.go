@@ -0,0 +1,144 @@
+package featureserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// Minimal protocol buffer encoder to build test messages
+type pbfMessage []byte
+
+func (m pbfMessage) varint(field uint64, v uint64) pbfMessage {
+	m = binary.AppendUvarint(m, field<<3|pbfWireVarint)
+	return binary.AppendUvarint(m, v)
+}
+
+func (m pbfMessage) double(field uint64, v float64) pbfMessage {
+	m = binary.AppendUvarint(m, field<<3|pbfWireFixed64)
+	return binary.LittleEndian.AppendUint64(m, math.Float64bits(v))
+}
+
+func (m pbfMessage) bytes(field uint64, v []byte) pbfMessage {
+	m = binary.AppendUvarint(m, field<<3|pbfWireBytes)
+	m = binary.AppendUvarint(m, uint64(len(v)))
+	return append(m, v...)
+}
+
+func (m pbfMessage) packed(field uint64, values []uint64) pbfMessage {
+	var packed []byte
+	for _, v := range values {
+		packed = binary.AppendUvarint(packed, v)
+	}
+	return m.bytes(field, packed)
+}
+
+func zigzagEncode(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func TestDecodePBFQueryResults(t *testing.T) {
+	t.Run("Polyline with z values", func(t *testing.T) {
+		sr := pbfMessage{}.varint(1, 102100).varint(2, 3857)
+		transform := pbfMessage{}.
+			varint(1, 0).
+			bytes(2, pbfMessage{}.double(1, 0.5).double(2, 0.5)).
+			bytes(3, pbfMessage{}.double(1, 100).double(2, 200))
+
+		// Two paths of two points, each delta encoded from the start of its path
+		geometry := pbfMessage{}.
+			packed(2, []uint64{2, 2}).
+			packed(3, []uint64{
+				zigzagEncode(2), zigzagEncode(4), zigzagEncode(10),
+				zigzagEncode(2), zigzagEncode(-2), zigzagEncode(1),
+				zigzagEncode(10), zigzagEncode(10), zigzagEncode(5),
+				zigzagEncode(-4), zigzagEncode(0), zigzagEncode(5),
+			})
+
+		feature := pbfMessage{}.
+			bytes(1, pbfMessage{}.varint(6, 7)).
+			bytes(1, pbfMessage{}.bytes(1, []byte("Main St"))).
+			bytes(1, pbfMessage{}).
+			bytes(2, geometry)
+
+		featureResult := pbfMessage{}.
+			bytes(1, []byte("objectid")).
+			varint(7, 2).
+			bytes(8, sr).
+			varint(9, 1).
+			varint(10, 1).
+			bytes(12, transform).
+			bytes(13, pbfMessage{}.bytes(1, []byte("objectid")).varint(2, 6)).
+			bytes(13, pbfMessage{}.bytes(1, []byte("name")).varint(2, 4)).
+			bytes(13, pbfMessage{}.bytes(1, []byte("rotation")).varint(2, 0)).
+			bytes(15, feature)
+
+		collection := pbfMessage{}.
+			bytes(1, []byte("1.0")).
+			bytes(2, pbfMessage{}.bytes(1, featureResult))
+
+		var features []Feature
+		results, err := decodePBFQueryResults(collection, true, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to decode pbf query results: %v", err)
+		}
+
+		if results.ObjectIDFieldName != "objectid" {
+			t.Errorf("expected object id field name objectid, got: %s", results.ObjectIDFieldName)
+		}
+		if results.GeometryType != GeometryTypePolyline {
+			t.Errorf("expected geometry type %s, got: %s", GeometryTypePolyline, results.GeometryType)
+		}
+		if !results.ExceededTransferLimit || !results.HasZ || results.HasM {
+			t.Errorf("expected exceeded transfer limit and z values only, got: %v %v %v", results.ExceededTransferLimit, results.HasZ, results.HasM)
+		}
+		if results.SpatialReference == nil || results.SpatialReference.LatestWKID != 3857 {
+			t.Errorf("expected spatial reference 3857, got: %v", results.SpatialReference)
+		}
+		if len(results.Fields) != 3 || results.Fields[0].Type != FieldTypeOID || results.Fields[1].Type != FieldTypeString {
+			t.Errorf("expected oid, string and small int fields, got: %+v", results.Fields)
+		}
+
+		if len(features) != 1 {
+			t.Fatalf("expected 1 feature, got: %d", len(features))
+		}
+
+		var attributes struct {
+			ObjectID int    `json:"objectid"`
+			Name     string `json:"name"`
+			Rotation *int16 `json:"rotation"`
+		}
+		if err := json.Unmarshal(features[0].Attributes, &attributes); err != nil {
+			t.Fatalf("failed to unmarshal attributes: %v", err)
+		}
+		if attributes.ObjectID != 7 || attributes.Name != "Main St" || attributes.Rotation != nil {
+			t.Errorf("expected attributes {7 Main St nil}, got: %+v", attributes)
+		}
+
+		g, ok := features[0].Geometry.(GeometryPolyline)
+		if !ok {
+			t.Fatalf("expected polyline geometry, got: %T", features[0].Geometry)
+		}
+
+		expect := [][][3]float64{
+			{{101, 198, 10}, {102, 199, 11}},
+			{{105, 195, 5}, {103, 195, 10}},
+		}
+
+		if len(g.Paths) != len(expect) {
+			t.Fatalf("expected %d paths, got: %d", len(expect), len(g.Paths))
+		}
+		for i, path := range expect {
+			for j, c := range path {
+				got := g.Paths[i][j]
+				if got.X != c[0] || got.Y != c[1] || got.Z == nil || *got.Z != c[2] {
+					t.Errorf("expected path %d point %d to be %v, got: %v,%v,%v", i, j, c, got.X, got.Y, fmtFloat(got.Z))
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package featureserver
+
+const (
+	// Quantizes the geometries for display, removing vertices that fall on the same pixel
+	QuantizationModeView = "view"
+	// Quantizes the geometries for editing, keeping all the vertices
+	QuantizationModeEdit = "edit"
+)
+
+const (
+	OriginPositionUpperLeft = "upperLeft"
+	OriginPositionLowerLeft = "lowerLeft"
+)
+
+// Asks the server to return integer coordinates on a grid, delta encoded from
+// the previous vertex of the same path or ring. The results are turned back
+// in to real coordinates when decoded.
+type QuantizationParameters struct {
+	// Can be one of:
+	//  - QuantizationModeView
+	//  - QuantizationModeEdit
+	Mode string `json:"mode"`
+	// Can be one of:
+	//  - OriginPositionUpperLeft
+	//  - OriginPositionLowerLeft
+	// The default is OriginPositionUpperLeft.
+	OriginPosition string `json:"originPosition,omitempty"`
+	// The size of one grid cell in the units of OutSR, or of the layer if OutSR isn't set
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// The extent the grid covers. If not set, the extent of the layer is used.
+	Extent *GeometryEnvelope `json:"extent,omitempty"`
+}
+
+// Returned with quantized results to turn the grid coordinates back in to
+// real coordinates
+type QuantizationTransform struct {
+	// Can be one of:
+	//  - OriginPositionUpperLeft
+	//  - OriginPositionLowerLeft
+	OriginPosition string `json:"originPosition"`
+	// x, y and optionally z and m scales
+	Scale []float64 `json:"scale"`
+	// x, y and optionally z and m translations
+	Translate []float64 `json:"translate"`
+}
+
+func (t QuantizationTransform) coordinate(c Coordinate) Coordinate {
+	at := func(values []float64, i int, fallback float64) float64 {
+		if i < len(values) {
+			return values[i]
+		}
+		return fallback
+	}
+
+	c.X = at(t.Translate, 0, 0) + c.X*at(t.Scale, 0, 1)
+	if t.OriginPosition == OriginPositionLowerLeft {
+		c.Y = at(t.Translate, 1, 0) + c.Y*at(t.Scale, 1, 1)
+	} else {
+		c.Y = at(t.Translate, 1, 0) - c.Y*at(t.Scale, 1, 1)
+	}
+	if c.Z != nil && len(t.Scale) > 2 {
+		z := at(t.Translate, 2, 0) + *c.Z*nonZero(t.Scale[2])
+		c.Z = &z
+	}
+	if c.M != nil && len(t.Scale) > 3 {
+		m := at(t.Translate, 3, 0) + *c.M*nonZero(t.Scale[3])
+		c.M = &m
+	}
+
+	return c
+}
+
+// Turns the delta encoded grid coordinates of a part back in to real coordinates
+func (t QuantizationTransform) part(cs []Coordinate) []Coordinate {
+	part := make([]Coordinate, len(cs))
+	var x, y float64
+	for i, c := range cs {
+		x += c.X
+		y += c.Y
+		c.X, c.Y = x, y
+		part[i] = t.coordinate(c)
+	}
+	return part
+}
+
+func (t QuantizationTransform) parts(parts [][]Coordinate) [][]Coordinate {
+	dequantized := make([][]Coordinate, len(parts))
+	for i, p := range parts {
+		dequantized[i] = t.part(p)
+	}
+	return dequantized
+}
+
+// Turns a quantized geometry back in to real coordinates. Points are not delta
+// encoded, every other geometry is.
+func dequantizeGeometry(geometry interface{}, t QuantizationTransform) interface{} {
+	switch g := geometry.(type) {
+	case GeometryPoint:
+		c := t.coordinate(Coordinate{X: g.X, Y: g.Y, Z: g.Z, M: g.M})
+		g.X, g.Y, g.Z, g.M = c.X, c.Y, c.Z, c.M
+		return g
+	case GeometryMultiPoint:
+		g.Points = t.part(g.Points)
+		return g
+	case GeometryPolyline:
+		g.Paths = t.parts(g.Paths)
+		return g
+	case GeometryPolygon:
+		g.Rings = t.parts(g.Rings)
+		return g
+	default:
+		return geometry
+	}
+}
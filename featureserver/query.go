@@ -9,20 +9,154 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mitchellh/mapstructure"
 )
 
+const (
+	// Part of a feature from feature class 1 is contained in a feature from feature class 2.
+	SpatialRelIntersects = "esriSpatialRelIntersects"
+	// Part or all of a feature from feature class 1 is contained within a feature from feature class 2.
+	SpatialRelContains = "esriSpatialRelContains"
+	// The feature from feature class 1 crosses a feature from feature class 2.
+	SpatialRelCrosses = "esriSpatialRelCrosses"
+	// The envelope of feature class 1 intersects with the envelope of feature class 2.
+	SpatialRelEnvelopeIntersects = "esriSpatialRelEnvelopeIntersects"
+	// The envelope of the query feature class intersects the index entry for the target feature class.
+	SpatialRelIndexIntersects = "esriSpatialRelIndexIntersects"
+	// Features from feature class 1 overlap features in feature class 2.
+	SpatialRelOverlaps = "esriSpatialRelOverlaps"
+	// The feature from feature class 1 touches the border of a feature from feature class 2.
+	SpatialRelTouches = "esriSpatialRelTouches"
+	// The feature from feature class 1 is completely enclosed by the feature from feature class 2.
+	SpatialRelWithin = "esriSpatialRelWithin"
+	// Allows specifying any relationship defined using the Shape Comparison Language.
+	SpatialRelRelation = "esriSpatialRelRelation"
+)
+
+const (
+	UnitsMeter          = "esriSRUnit_Meter"
+	UnitsKilometer      = "esriSRUnit_Kilometer"
+	UnitsFoot           = "esriSRUnit_Foot"
+	UnitsStatuteMile    = "esriSRUnit_StatuteMile"
+	UnitsNauticalMile   = "esriSRUnit_NauticalMile"
+	UnitsUSNauticalMile = "esriSRUnit_USNauticalMile"
+)
+
+const (
+	OrderAsc  = "ASC"
+	OrderDesc = "DESC"
+)
+
+type OrderByField struct {
+	Field string
+	// Can be one of:
+	//  - OrderAsc
+	//  - OrderDesc
+	// The default is OrderAsc.
+	Order string
+}
+
+func (o OrderByField) String() string {
+	if o.Order == "" {
+		return o.Field
+	}
+	return fmt.Sprintf("%s %s", o.Field, o.Order)
+}
+
+const (
+	// Esri JSON, the default
+	FormatJSON = "json"
+	// Protocol buffers, smaller and faster to decode than json
+	FormatPBF = "pbf"
+	// GeoJSON, the geometries are always in WGS84
+	FormatGeoJSON = "geojson"
+)
+
 type QueryVariables struct {
 	// A SQL where clause for the query filter. Any legal SQL where clause operating on the fields in the layer is allowed.
 	Where string
+	// The object ids of the features to query. Combined with the where clause if both are set.
+	ObjectIDs []int
 	// If true, the result set includes the geometry associated with each feature. The default is false.
 	ReturnGeometry bool
 	// A comma delimited list of field names. If you specify the shape field in the list of return fields, it is ignored. To request geometry, set returnGeometry to true.
 	OutFields []string
+	// Returns the geometries on an integer grid to make the response smaller.
+	QuantizationParameters *QuantizationParameters
+	// Generalizes the geometries so no vertex is further than this distance from the original geometry, in the units of OutSR. Only used when greater than zero.
+	MaxAllowableOffset float64
+	// The number of decimal places of the returned geometries. If not set, the server default is used.
+	GeometryPrecision *int
+	// If true, curved segments are returned as true curves instead of being densified by the server. They are decoded by densifying each curve in to straight segments.
+	ReturnTrueCurves bool
+	// Only returns features of time aware layers within the time extent.
+	Time *TimeExtent
+	// The format of the response. The results are decoded in to the same types regardless of the format. Can be one of:
+	//  - FormatJSON
+	//  - FormatPBF
+	//  - FormatGeoJSON
+	// The default is FormatJSON.
+	Format string
 	// Limits the number of features returned by a query to a specified number.
 	ResultRecordCount int
+	// The number of features to skip before returning results. Only used when greater than zero.
+	ResultOffset int
+	// The fields to sort the results by.
+	OrderByFields []OrderByField
+	// If true, only distinct combinations of the values of OutFields are returned. Requires ReturnGeometry to be false.
+	ReturnDistinctValues bool
+	// The statistics to compute. When set, each feature of the results is a row of statistics in its attributes and has no geometry.
+	OutStatistics []OutStatistic
+	// The fields to group the statistics by.
+	GroupByFieldsForStatistics []string
+	// A SQL clause to filter the groups of statistics, for example "COUNT(objectid) > 10". Only used with OutStatistics.
+	Having string
+	// If true, z values are included in the geometries of the results if the layer has them. The default is false.
+	// Only set it for layers with z values, otherwise the features of QueryStream are held back until the response ends.
+	ReturnZ bool
+	// If true, m values are included in the geometries of the results if the layer has them. The default is false.
+	// Only set it for layers with m values, otherwise the features of QueryStream are held back until the response ends.
+	ReturnM bool
+	// The spatial reference of the input geometry. If not set, the geometry is assumed to be in the spatial reference of the layer.
+	InSR *SpatialReference
+	// The spatial reference of the returned geometry. If not set, the geometry is returned in the spatial reference of the layer.
+	OutSR *SpatialReference
+	// The geometry to apply as the spatial filter. Can be one of:
+	//  - GeometryPoint
+	//  - GeometryMultiPoint
+	//  - GeometryPolyline
+	//  - GeometryPolygon
+	//  - GeometryEnvelope
+	Geometry interface{}
+	// The type of geometry specified by the geometry parameter. If not set, it is derived from the geometry.
+	GeometryType string
+	// The spatial relationship to be applied to the input geometry while performing the query. The default is SpatialRelIntersects. Can be one of:
+	//  - SpatialRelIntersects
+	//  - SpatialRelContains
+	//  - SpatialRelCrosses
+	//  - SpatialRelEnvelopeIntersects
+	//  - SpatialRelIndexIntersects
+	//  - SpatialRelOverlaps
+	//  - SpatialRelTouches
+	//  - SpatialRelWithin
+	//  - SpatialRelRelation
+	SpatialRel string
+	// The spatial relate function used when SpatialRel is SpatialRelRelation, for example "FFFTTT***".
+	RelationParam string
+	// The buffer distance for the input geometry. Only used when greater than zero.
+	Distance float64
+	// The unit for calculating the buffer distance. Can be one of:
+	//  - UnitsMeter
+	//  - UnitsKilometer
+	//  - UnitsFoot
+	//  - UnitsStatuteMile
+	//  - UnitsNauticalMile
+	//  - UnitsUSNauticalMile
+	Units string
 }
 
 type QueryResults struct {
@@ -32,20 +166,165 @@ type QueryResults struct {
 	//  - GeometryTypeNone
 	//  - GeometryTypePoint
 	//  - GeometryTypeMultiPoint
-	GeometryType string    `json:"geometryType"`
-	Fields       []Field   `json:"fields"`
-	Features     []Feature `json:"features"`
+	//  - GeometryTypePolyline
+	//  - GeometryTypePolygon
+	//  - GeometryTypeEnvelope
+	GeometryType     string            `json:"geometryType"`
+	HasZ             bool              `json:"hasZ"`
+	HasM             bool              `json:"hasM"`
+	SpatialReference *SpatialReference `json:"spatialReference"`
+	Fields           []Field           `json:"fields"`
+	Features         []Feature         `json:"features"`
+	// True when there are more features matching the query than were returned
+	ExceededTransferLimit bool `json:"exceededTransferLimit"`
+	// Only returned for quantized queries, the geometries of the features are already dequantized with it
+	Transform *QuantizationTransform `json:"transform,omitempty"`
+	// Only returned when the query asks for the extent of the results
+	Extent *GeometryEnvelope `json:"extent,omitempty"`
 }
 
 func (l *Layer) Query(ctx context.Context, variables QueryVariables) (results QueryResults, err error) {
+	var features []Feature
+
+	results, err = l.QueryStream(ctx, variables, func(f Feature) error {
+		features = append(features, f)
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+
+	results.Features = features
+
+	return results, nil
+}
+
+// Like Query but each feature is passed to fn as soon as it is decoded instead
+// of being collected in to the results, so only one feature is held in memory
+// at a time. The returned results have no features. Returning an error from fn
+// stops the query.
+func (l *Layer) QueryStream(ctx context.Context, variables QueryVariables, fn func(f Feature) error) (results QueryResults, err error) {
+	body, err := l.queryBody(ctx, variables, nil)
+	if err != nil {
+		return results, err
+	}
+	defer body.Close()
+
+	switch variables.Format {
+	case FormatPBF:
+		return decodePBFResponse(body, variables.ReturnGeometry, fn)
+	case FormatGeoJSON:
+		return decodeGeoJSONQueryResults(body, variables.ReturnGeometry, fn)
+	default:
+		return decodeQueryResults(body, variables, fn)
+	}
+}
+
+type QueryIDsResults struct {
+	ObjectIDFieldName string `json:"objectIdFieldName"`
+	ObjectIDs         []int  `json:"objectIds"`
+}
+
+// Returns only the object ids of the features matching the query. Unlike a
+// regular query the ids are not limited by the server's maxRecordCount.
+func (l *Layer) QueryIDs(ctx context.Context, variables QueryVariables) (results QueryIDsResults, err error) {
+	respBody, err := l.query(ctx, variables, map[string]string{"returnIdsOnly": "true"})
+	if err != nil {
+		return results, err
+	}
+
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return results, fmt.Errorf("failed to decode query ids results: %w", err)
+	}
+
+	return results, nil
+}
+
+// Returns only the number of features matching the query
+func (l *Layer) QueryCount(ctx context.Context, variables QueryVariables) (count int, err error) {
+	respBody, err := l.query(ctx, variables, map[string]string{"returnCountOnly": "true"})
+	if err != nil {
+		return count, err
+	}
+
+	var results struct {
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return count, fmt.Errorf("failed to decode query count results: %w", err)
+	}
+
+	return results.Count, nil
+}
+
+type QueryExtentResults struct {
+	// Number of features matching the query
+	Count int `json:"count"`
+	// Extent of the features matching the query
+	Extent GeometryEnvelope `json:"extent"`
+}
+
+// Returns only the extent and number of the features matching the query
+func (l *Layer) QueryExtent(ctx context.Context, variables QueryVariables) (results QueryExtentResults, err error) {
+	respBody, err := l.query(ctx, variables, map[string]string{
+		"returnExtentOnly": "true",
+		"returnCountOnly":  "true",
+	})
+	if err != nil {
+		return results, err
+	}
+
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return results, fmt.Errorf("failed to decode query extent results: %w", err)
+	}
+
+	return results, nil
+}
+
+// Sends a query request to the layer and returns the response body if it isn't
+// an error. The fields are written in addition to the ones from the variables.
+func (l *Layer) query(ctx context.Context, variables QueryVariables, fields map[string]string) ([]byte, error) {
+	variables.Format = FormatJSON
+
+	body, err := l.queryBody(ctx, variables, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	respBody, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var respJSON map[string]interface{}
+	if err := json.Unmarshal(respBody, &respJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	respError, ok := respJSON["error"]
+	if ok {
+		var errRespErr ErrResponseError
+		if err := mapstructure.Decode(respError, &errRespErr); err != nil {
+			return nil, fmt.Errorf("failed to decode error response: %w", err)
+		}
+		return nil, errRespErr
+	}
+
+	return respBody, nil
+}
+
+// Sends a query request to the layer and returns the response body without
+// reading it. The caller must close the body.
+func (l *Layer) queryBody(ctx context.Context, variables QueryVariables, fields map[string]string) (io.ReadCloser, error) {
 	u, err := url.Parse(l.fs.url)
 	if err != nil {
-		return results, fmt.Errorf("failed to parse url: %w", err)
+		return nil, fmt.Errorf("failed to parse url: %w", err)
 	}
 
 	p, err := url.JoinPath(u.Path, fmt.Sprintf("%d", l.ID), "query")
 	if err != nil {
-		return results, fmt.Errorf("failed to join path: %w", err)
+		return nil, fmt.Errorf("failed to join path: %w", err)
 	}
 
 	u.Path = p
@@ -54,100 +333,238 @@ func (l *Layer) Query(ctx context.Context, variables QueryVariables) (results Qu
 	formBodyWriter := multipart.NewWriter(formBody)
 
 	if err := formBodyWriter.WriteField("where", variables.Where); err != nil {
-		return results, fmt.Errorf("failed to write 'where' field: %w", err)
+		return nil, fmt.Errorf("failed to write 'where' field: %w", err)
 	}
 
-	if err := formBodyWriter.WriteField("f", "json"); err != nil {
-		return results, fmt.Errorf("failed to write 'f' field: %w", err)
+	if variables.ObjectIDs != nil {
+		objectIDs := make([]string, len(variables.ObjectIDs))
+		for i, objectID := range variables.ObjectIDs {
+			objectIDs[i] = strconv.Itoa(objectID)
+		}
+		if err := formBodyWriter.WriteField("objectIds", strings.Join(objectIDs, ",")); err != nil {
+			return nil, fmt.Errorf("failed to write 'objectIds' field: %w", err)
+		}
+	}
+
+	format := variables.Format
+	if format == "" {
+		format = FormatJSON
+	}
+
+	if err := formBodyWriter.WriteField("f", format); err != nil {
+		return nil, fmt.Errorf("failed to write 'f' field: %w", err)
 	}
 
 	if err := formBodyWriter.WriteField("returnGeometry", fmt.Sprintf("%t", variables.ReturnGeometry)); err != nil {
-		return results, fmt.Errorf("failed to write 'returnGeometry' field: %w", err)
+		return nil, fmt.Errorf("failed to write 'returnGeometry' field: %w", err)
+	}
+
+	if variables.ReturnDistinctValues {
+		if err := formBodyWriter.WriteField("returnDistinctValues", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write 'returnDistinctValues' field: %w", err)
+		}
+	}
+
+	if variables.ReturnZ {
+		if err := formBodyWriter.WriteField("returnZ", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write 'returnZ' field: %w", err)
+		}
+	}
+
+	if variables.ReturnM {
+		if err := formBodyWriter.WriteField("returnM", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write 'returnM' field: %w", err)
+		}
+	}
+
+	if variables.Geometry != nil {
+		geometryType := variables.GeometryType
+		if geometryType == GeometryTypeNone {
+			geometryType, err = geometryTypeOf(variables.Geometry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get 'geometryType': %w", err)
+			}
+		}
+
+		geometryJSON, err := json.Marshal(variables.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'geometry' field: %w", err)
+		}
+
+		if err := formBodyWriter.WriteField("geometry", string(geometryJSON)); err != nil {
+			return nil, fmt.Errorf("failed to write 'geometry' field: %w", err)
+		}
+
+		if err := formBodyWriter.WriteField("geometryType", geometryType); err != nil {
+			return nil, fmt.Errorf("failed to write 'geometryType' field: %w", err)
+		}
+
+		if variables.SpatialRel != "" {
+			if err := formBodyWriter.WriteField("spatialRel", variables.SpatialRel); err != nil {
+				return nil, fmt.Errorf("failed to write 'spatialRel' field: %w", err)
+			}
+		}
+
+		if variables.RelationParam != "" {
+			if err := formBodyWriter.WriteField("relationParam", variables.RelationParam); err != nil {
+				return nil, fmt.Errorf("failed to write 'relationParam' field: %w", err)
+			}
+		}
+
+		if variables.Distance > 0 {
+			if err := formBodyWriter.WriteField("distance", strconv.FormatFloat(variables.Distance, 'f', -1, 64)); err != nil {
+				return nil, fmt.Errorf("failed to write 'distance' field: %w", err)
+			}
+
+			if variables.Units != "" {
+				if err := formBodyWriter.WriteField("units", variables.Units); err != nil {
+					return nil, fmt.Errorf("failed to write 'units' field: %w", err)
+				}
+			}
+		}
+	}
+
+	if variables.Time != nil {
+		if err := formBodyWriter.WriteField("time", variables.Time.queryValue()); err != nil {
+			return nil, fmt.Errorf("failed to write 'time' field: %w", err)
+		}
+	}
+
+	if variables.QuantizationParameters != nil {
+		quantizationParametersJSON, err := json.Marshal(variables.QuantizationParameters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'quantizationParameters' field: %w", err)
+		}
+		if err := formBodyWriter.WriteField("quantizationParameters", string(quantizationParametersJSON)); err != nil {
+			return nil, fmt.Errorf("failed to write 'quantizationParameters' field: %w", err)
+		}
+	}
+
+	if variables.MaxAllowableOffset > 0 {
+		if err := formBodyWriter.WriteField("maxAllowableOffset", strconv.FormatFloat(variables.MaxAllowableOffset, 'f', -1, 64)); err != nil {
+			return nil, fmt.Errorf("failed to write 'maxAllowableOffset' field: %w", err)
+		}
+	}
+
+	if variables.GeometryPrecision != nil {
+		if err := formBodyWriter.WriteField("geometryPrecision", fmt.Sprintf("%d", *variables.GeometryPrecision)); err != nil {
+			return nil, fmt.Errorf("failed to write 'geometryPrecision' field: %w", err)
+		}
+	}
+
+	if variables.ReturnTrueCurves {
+		if err := formBodyWriter.WriteField("returnTrueCurves", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write 'returnTrueCurves' field: %w", err)
+		}
+	}
+
+	if variables.InSR != nil {
+		inSRJSON, err := json.Marshal(variables.InSR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'inSR' field: %w", err)
+		}
+		if err := formBodyWriter.WriteField("inSR", string(inSRJSON)); err != nil {
+			return nil, fmt.Errorf("failed to write 'inSR' field: %w", err)
+		}
+	}
+
+	if variables.OutSR != nil {
+		outSRJSON, err := json.Marshal(variables.OutSR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'outSR' field: %w", err)
+		}
+		if err := formBodyWriter.WriteField("outSR", string(outSRJSON)); err != nil {
+			return nil, fmt.Errorf("failed to write 'outSR' field: %w", err)
+		}
 	}
 
 	if variables.OutFields != nil {
 		if err := formBodyWriter.WriteField("outFields", strings.Join(variables.OutFields, ",")); err != nil {
-			return results, fmt.Errorf("failed to write 'outFields' field: %w", err)
+			return nil, fmt.Errorf("failed to write 'outFields' field: %w", err)
 		}
 	}
 
 	if variables.ResultRecordCount != -1 {
 		if err := formBodyWriter.WriteField("resultRecordCount", fmt.Sprintf("%d", variables.ResultRecordCount)); err != nil {
-			return results, fmt.Errorf("failed to write 'resultRecordCount' field: %w", err)
+			return nil, fmt.Errorf("failed to write 'resultRecordCount' field: %w", err)
 		}
 	}
 
-	if err := formBodyWriter.Close(); err != nil {
-		return results, fmt.Errorf("failed to close multipart writer: %w", err)
+	if variables.OutStatistics != nil {
+		outStatisticsJSON, err := json.Marshal(variables.OutStatistics)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal 'outStatistics' field: %w", err)
+		}
+		if err := formBodyWriter.WriteField("outStatistics", string(outStatisticsJSON)); err != nil {
+			return nil, fmt.Errorf("failed to write 'outStatistics' field: %w", err)
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), formBody)
-	if err != nil {
-		return results, fmt.Errorf("failed to create request: %w", err)
+	if variables.GroupByFieldsForStatistics != nil {
+		if err := formBodyWriter.WriteField("groupByFieldsForStatistics", strings.Join(variables.GroupByFieldsForStatistics, ",")); err != nil {
+			return nil, fmt.Errorf("failed to write 'groupByFieldsForStatistics' field: %w", err)
+		}
 	}
 
-	req.Header.Add("Content-Type", formBodyWriter.FormDataContentType())
-
-	resp, err := l.fs.httpClient.Do(req)
-	if err != nil {
-		return results, fmt.Errorf("failed to do request: %w", err)
+	if variables.Having != "" {
+		if err := formBodyWriter.WriteField("having", variables.Having); err != nil {
+			return nil, fmt.Errorf("failed to write 'having' field: %w", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		switch resp.StatusCode {
-		case http.StatusNotFound:
-			return results, ErrNotFound
-		default:
-			return results, fmt.Errorf("unhandled status code: %d", resp.StatusCode)
+	if variables.ResultOffset > 0 {
+		if err := formBodyWriter.WriteField("resultOffset", fmt.Sprintf("%d", variables.ResultOffset)); err != nil {
+			return nil, fmt.Errorf("failed to write 'resultOffset' field: %w", err)
 		}
 	}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return results, fmt.Errorf("failed to read response body: %w", err)
+	if len(variables.OrderByFields) > 0 {
+		orderByFields := make([]string, len(variables.OrderByFields))
+		for i, o := range variables.OrderByFields {
+			orderByFields[i] = o.String()
+		}
+		if err := formBodyWriter.WriteField("orderByFields", strings.Join(orderByFields, ",")); err != nil {
+			return nil, fmt.Errorf("failed to write 'orderByFields' field: %w", err)
+		}
 	}
 
-	var respJSON map[string]interface{}
-	if err := json.Unmarshal(respBody, &respJSON); err != nil {
-		return results, fmt.Errorf("failed to unmarshal response body: %w", err)
+	fieldNames := make([]string, 0, len(fields))
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
 	}
+	sort.Strings(fieldNames)
 
-	respError, ok := respJSON["error"]
-	if ok {
-		var errRespErr ErrResponseError
-		if err := mapstructure.Decode(respError, &errRespErr); err != nil {
-			return results, fmt.Errorf("failed to decode error response: %w", err)
+	for _, name := range fieldNames {
+		if err := formBodyWriter.WriteField(name, fields[name]); err != nil {
+			return nil, fmt.Errorf("failed to write '%s' field: %w", name, err)
 		}
-		return results, errRespErr
 	}
 
-	if err := json.Unmarshal(respBody, &results); err != nil {
-		return results, fmt.Errorf("failed to decode query results: %w", err)
+	if err := formBodyWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
 	}
 
-	for i, f := range results.Features {
-		if !variables.ReturnGeometry {
-			results.Features[i].Geometry = GeometryNone{}
-			continue
-		}
-		switch results.GeometryType {
-		case GeometryTypePoint:
-			var geometry GeometryPoint
-			if err := mapstructure.Decode(f.Geometry, &geometry); err != nil {
-				return results, fmt.Errorf("failed to decode point geometry: %w", err)
-			}
-			results.Features[i].Geometry = geometry
-		case GeometryTypeMultiPoint:
-			var geometry GeometryMultiPoint
-			if err := mapstructure.Decode(f.Geometry, &geometry); err != nil {
-				return results, fmt.Errorf("failed to decode multipoint geometry: %w", err)
-			}
-			results.Features[i].Geometry = geometry
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), formBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", formBodyWriter.FormDataContentType())
+
+	resp, err := l.fs.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to do request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, ErrNotFound
 		default:
-			return results, fmt.Errorf("unhandled geometry type: %s", results.GeometryType)
+			return nil, fmt.Errorf("unhandled status code: %d", resp.StatusCode)
 		}
 	}
 
-	return results, nil
+	return resp.Body, nil
 }
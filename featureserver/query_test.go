@@ -3,6 +3,9 @@ package featureserver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/TheAschr/arcgis"
@@ -182,6 +185,19 @@ func TestLayerQuery(t *testing.T) {
 							}
 						},
 					},
+					// Only the geometries of the line and polygon layers are checked
+					{
+						ID:                1,
+						ObjectIDFieldName: "objectid",
+						GlobalIDFieldName: "",
+						GeometryType:      "esriGeometryPolyline",
+					},
+					{
+						ID:                2,
+						ObjectIDFieldName: "objectid",
+						GlobalIDFieldName: "",
+						GeometryType:      "esriGeometryPolygon",
+					},
 				},
 			},
 		}
@@ -198,6 +214,9 @@ func TestLayerQuery(t *testing.T) {
 				for i, field := range layerTest.Fields {
 					outFields[i] = field.Name
 				}
+				if len(outFields) == 0 {
+					outFields = []string{"*"}
+				}
 
 				results, err := fsc.Layer(layerTest.ID).Query(context.Background(), QueryVariables{
 					Where:          "1=1",
@@ -220,11 +239,18 @@ func TestLayerQuery(t *testing.T) {
 					t.Errorf("expected geometry type %s, got: %s", layerTest.GeometryType, results.GeometryType)
 				}
 
-				if len(results.Fields) != len(layerTest.Fields) {
+				if layerTest.GeometryType != GeometryTypeNone && results.SpatialReference == nil {
+					t.Errorf("expected spatial reference, got none")
+				}
+
+				if layerTest.Fields != nil && len(results.Fields) != len(layerTest.Fields) {
 					t.Errorf("expected %d fields, got: %d", len(layerTest.Fields), len(results.Fields))
 				}
 
 				for i, field := range results.Fields {
+					if i >= len(layerTest.Fields) {
+						break
+					}
 					if field.Name != layerTest.Fields[i].Name {
 						t.Errorf("expected field name %s, got: %s", layerTest.Fields[i].Name, field.Name)
 					}
@@ -266,6 +292,22 @@ func TestLayerQuery(t *testing.T) {
 						if len(g.Points) == 0 {
 							t.Errorf("expected multipoint, got none")
 						}
+					case GeometryPolyline:
+						if layerTest.GeometryType != GeometryTypePolyline {
+							t.Errorf("expected geometry type %s, got: %s", layerTest.GeometryType, GeometryTypePolyline)
+						}
+
+						if len(g.Paths) == 0 {
+							t.Errorf("expected polyline paths, got none")
+						}
+					case GeometryPolygon:
+						if layerTest.GeometryType != GeometryTypePolygon {
+							t.Errorf("expected geometry type %s, got: %s", layerTest.GeometryType, GeometryTypePolygon)
+						}
+
+						if len(g.Rings) == 0 {
+							t.Errorf("expected polygon rings, got none")
+						}
 					default:
 						t.Errorf("unhandled geometry type: %T", g)
 					}
@@ -279,3 +321,361 @@ func TestLayerQuery(t *testing.T) {
 
 	})
 }
+
+func TestLayerQueryGeometries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1/query":
+			fmt.Fprint(w, `{"geometryType":"esriGeometryPolyline","hasZ":true,"spatialReference":{"wkid":102100},"features":[{"attributes":{"objectid":1},"geometry":{"paths":[[[1,2,5],[3,4,6]],[[5,6,7],[7,8,8]]]}}]}`)
+		case "/2/query":
+			fmt.Fprint(w, `{"geometryType":"esriGeometryPolygon","spatialReference":{"wkid":102100},"features":[{"attributes":{"objectid":1},"geometry":{"rings":[[[0,0],[0,10],[10,10],[10,0],[0,0]],[[2,2],[4,2],[4,4],[2,4],[2,2]]]}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	t.Run("Polyline", func(t *testing.T) {
+		results, err := fsc.Layer(1).Query(context.Background(), QueryVariables{Where: "1=1", ReturnGeometry: true, ReturnZ: true})
+		if err != nil {
+			t.Fatalf("failed to query layer: %v", err)
+		}
+
+		g, ok := results.Features[0].Geometry.(GeometryPolyline)
+		if !ok {
+			t.Fatalf("expected GeometryPolyline, got: %T", results.Features[0].Geometry)
+		}
+		if !g.HasZ || len(g.Paths) != 2 || len(g.Paths[1]) != 2 {
+			t.Fatalf("expected 2 paths of 2 points with z values, got: %+v", g)
+		}
+		if c := g.Paths[1][1]; c.X != 7 || c.Y != 8 || fmtFloat(c.Z) != "8" || c.M != nil {
+			t.Errorf("expected point 7,8,8, got: %v,%v,%v", c.X, c.Y, fmtFloat(c.Z))
+		}
+		if g.SpatialReference == nil || g.SpatialReference.WKID != 102100 {
+			t.Errorf("expected spatial reference 102100, got: %v", g.SpatialReference)
+		}
+	})
+
+	t.Run("Polygon", func(t *testing.T) {
+		results, err := fsc.Layer(2).Query(context.Background(), QueryVariables{Where: "1=1", ReturnGeometry: true})
+		if err != nil {
+			t.Fatalf("failed to query layer: %v", err)
+		}
+
+		g, ok := results.Features[0].Geometry.(GeometryPolygon)
+		if !ok {
+			t.Fatalf("expected GeometryPolygon, got: %T", results.Features[0].Geometry)
+		}
+		if g.HasZ || g.HasM || len(g.Rings) != 2 || len(g.Rings[0]) != 5 {
+			t.Fatalf("expected 2 rings without z and m values, got: %+v", g)
+		}
+		if c := g.Rings[1][2]; c.X != 4 || c.Y != 4 || c.Z != nil || c.M != nil {
+			t.Errorf("expected point 4,4, got: %+v", c)
+		}
+	})
+}
+
+func TestLayerQueryModes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+
+		switch {
+		case r.FormValue("returnExtentOnly") == "true":
+			fmt.Fprint(w, `{"count":2,"extent":{"xmin":1,"ymin":2,"xmax":3,"ymax":4,"spatialReference":{"wkid":4326}}}`)
+		case r.FormValue("returnCountOnly") == "true":
+			fmt.Fprint(w, `{"count":2}`)
+		case r.FormValue("returnIdsOnly") == "true":
+			fmt.Fprint(w, `{"objectIdFieldName":"objectid","objectIds":[7,9]}`)
+		default:
+			t.Errorf("expected a query mode")
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	l := fsc.Layer(0)
+
+	t.Run("Count", func(t *testing.T) {
+		count, err := l.QueryCount(context.Background(), QueryVariables{Where: "1=1"})
+		if err != nil {
+			t.Fatalf("failed to query count: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected count 2, got: %d", count)
+		}
+	})
+
+	t.Run("IDs", func(t *testing.T) {
+		results, err := l.QueryIDs(context.Background(), QueryVariables{Where: "1=1"})
+		if err != nil {
+			t.Fatalf("failed to query ids: %v", err)
+		}
+		if results.ObjectIDFieldName != "objectid" {
+			t.Errorf("expected object id field name objectid, got: %s", results.ObjectIDFieldName)
+		}
+		if fmt.Sprint(results.ObjectIDs) != "[7 9]" {
+			t.Errorf("expected object ids [7 9], got: %v", results.ObjectIDs)
+		}
+	})
+
+	t.Run("Extent", func(t *testing.T) {
+		results, err := l.QueryExtent(context.Background(), QueryVariables{Where: "1=1"})
+		if err != nil {
+			t.Fatalf("failed to query extent: %v", err)
+		}
+		if results.Count != 2 {
+			t.Errorf("expected count 2, got: %d", results.Count)
+		}
+		if results.Extent.XMin != 1 || results.Extent.YMin != 2 || results.Extent.XMax != 3 || results.Extent.YMax != 4 {
+			t.Errorf("expected extent 1,2,3,4, got: %+v", results.Extent)
+		}
+		if results.Extent.SpatialReference == nil || results.Extent.SpatialReference.WKID != 4326 {
+			t.Errorf("expected spatial reference 4326, got: %v", results.Extent.SpatialReference)
+		}
+	})
+}
+
+func TestLayerQueryStatistics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+
+		expectFields := map[string]string{
+			"outStatistics":              `[{"statisticType":"count","onStatisticField":"objectid","outStatisticFieldName":"total"},{"statisticType":"percentile_cont","onStatisticField":"rotation","outStatisticFieldName":"p90","statisticParameters":{"value":0.9}}]`,
+			"groupByFieldsForStatistics": "eventtype",
+			"having":                     "COUNT(objectid) > 1",
+			"orderByFields":              "total DESC",
+		}
+		for name, expect := range expectFields {
+			if got := r.FormValue(name); got != expect {
+				t.Errorf("expected '%s' to be '%s', got: '%s'", name, expect, got)
+			}
+		}
+
+		fmt.Fprint(w, `{"fields":[{"name":"eventtype","type":"esriFieldTypeInteger"},{"name":"total","type":"esriFieldTypeInteger"},{"name":"p90","type":"esriFieldTypeDouble"}],"features":[{"attributes":{"eventtype":1,"total":12,"p90":45.5}},{"attributes":{"eventtype":2,"total":3,"p90":10}}]}`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	results, err := fsc.Layer(0).Query(context.Background(), QueryVariables{
+		Where: "1=1",
+		OutStatistics: []OutStatistic{
+			{StatisticType: StatisticTypeCount, OnStatisticField: "objectid", OutStatisticFieldName: "total"},
+			{StatisticType: StatisticTypePercentileCont, OnStatisticField: "rotation", OutStatisticFieldName: "p90", StatisticParameters: &StatisticParameters{Value: 0.9}},
+		},
+		GroupByFieldsForStatistics: []string{"eventtype"},
+		Having:                     "COUNT(objectid) > 1",
+		OrderByFields:              []OrderByField{{Field: "total", Order: OrderDesc}},
+	})
+	if err != nil {
+		t.Fatalf("failed to query statistics: %v", err)
+	}
+
+	if len(results.Features) != 2 {
+		t.Fatalf("expected 2 rows, got: %d", len(results.Features))
+	}
+
+	var row struct {
+		EventType int     `json:"eventtype"`
+		Total     int     `json:"total"`
+		P90       float64 `json:"p90"`
+	}
+	if err := json.Unmarshal(results.Features[0].Attributes, &row); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if row.EventType != 1 || row.Total != 12 || row.P90 != 45.5 {
+		t.Errorf("expected row {1 12 45.5}, got: %+v", row)
+	}
+	if _, ok := results.Features[0].Geometry.(GeometryNone); !ok {
+		t.Errorf("expected no geometry, got: %T", results.Features[0].Geometry)
+	}
+}
+
+func TestLayerQuerySpatialFilter(t *testing.T) {
+	var form map[string][]string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+		form = r.MultipartForm.Value
+		fmt.Fprint(w, `{"features":[]}`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		variables QueryVariables
+		expect    map[string]string
+		absent    []string
+	}{
+		{
+			name: "Buffered point",
+			variables: QueryVariables{
+				Geometry:   GeometryPoint{X: 1, Y: 2},
+				SpatialRel: SpatialRelWithin,
+				Distance:   2.5,
+				Units:      UnitsKilometer,
+				InSR:       &SpatialReference{WKID: 4326},
+			},
+			expect: map[string]string{
+				"geometry":     `{"x":1,"y":2}`,
+				"geometryType": GeometryTypePoint,
+				"spatialRel":   SpatialRelWithin,
+				"distance":     "2.5",
+				"units":        UnitsKilometer,
+				"inSR":         `{"wkid":4326}`,
+			},
+			absent: []string{"relationParam"},
+		},
+		{
+			name: "Envelope relation",
+			variables: QueryVariables{
+				Geometry:      GeometryEnvelope{XMin: 1, YMin: 2, XMax: 3, YMax: 4},
+				GeometryType:  GeometryTypeEnvelope,
+				SpatialRel:    SpatialRelRelation,
+				RelationParam: "FFFTTT***",
+				Units:         UnitsMeter,
+			},
+			expect: map[string]string{
+				"geometry":      `{"xmin":1,"ymin":2,"xmax":3,"ymax":4}`,
+				"geometryType":  GeometryTypeEnvelope,
+				"spatialRel":    SpatialRelRelation,
+				"relationParam": "FFFTTT***",
+			},
+			// Units are only sent with a distance
+			absent: []string{"distance", "units", "inSR"},
+		},
+		{
+			name:      "No geometry",
+			variables: QueryVariables{SpatialRel: SpatialRelWithin, Distance: 10, Units: UnitsMeter},
+			absent:    []string{"geometry", "geometryType", "spatialRel", "distance", "units"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.variables.Where = "1=1"
+			if _, err := fsc.Layer(0).Query(context.Background(), test.variables); err != nil {
+				t.Fatalf("failed to query: %v", err)
+			}
+
+			for name, expect := range test.expect {
+				if got := fmt.Sprint(form[name]); got != fmt.Sprint([]string{expect}) {
+					t.Errorf("expected '%s' to be [%s], got: %s", name, expect, got)
+				}
+			}
+			for _, name := range test.absent {
+				if value, ok := form[name]; ok {
+					t.Errorf("expected no '%s', got: %v", name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestLayerQueryResultsExtent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"geometryType":"esriGeometryPoint","spatialReference":{"wkid":4326},"extent":{"xmin":1,"ymin":2,"xmax":3,"ymax":4,"mmin":0,"mmax":9,"spatialReference":{"wkid":4326}},"features":[]}`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	results, err := fsc.Layer(0).Query(context.Background(), QueryVariables{Where: "1=1"})
+	if err != nil {
+		t.Fatalf("failed to query layer: %v", err)
+	}
+
+	extent := results.Extent
+	if extent == nil {
+		t.Fatalf("expected extent")
+	}
+	if extent.XMin != 1 || extent.YMin != 2 || extent.XMax != 3 || extent.YMax != 4 {
+		t.Errorf("expected extent 1,2,3,4, got: %+v", extent)
+	}
+	if fmtFloat(extent.MMin) != "0" || fmtFloat(extent.MMax) != "9" || extent.ZMin != nil {
+		t.Errorf("expected extent m values 0 and 9 without z values, got: %+v", extent)
+	}
+	if extent.SpatialReference == nil || extent.SpatialReference.WKID != 4326 {
+		t.Errorf("expected extent spatial reference 4326, got: %v", extent.SpatialReference)
+	}
+}
+
+func TestLayerQuerySpatialReference(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+			return
+		}
+
+		expect := map[string]string{
+			"inSR":  `{"wkid":4326}`,
+			"outSR": `{"wkid":102100,"latestWkid":3857}`,
+		}
+		for name, value := range expect {
+			if got := r.FormValue(name); got != value {
+				t.Errorf("expected '%s' to be '%s', got: '%s'", name, value, got)
+			}
+		}
+
+		fmt.Fprint(w, `{"geometryType":"esriGeometryPolygon","spatialReference":{"wkid":102100,"latestWkid":3857},"features":[{"attributes":{},"geometry":{"rings":[[[0,0],[0,1],[1,1],[0,0]]]}},{"attributes":{},"geometry":{"rings":[[[2,2],[2,3],[3,3],[2,2]]]}}]}`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	results, err := fsc.Layer(0).Query(context.Background(), QueryVariables{
+		Where:          "1=1",
+		ReturnGeometry: true,
+		Geometry:       GeometryPoint{X: 1, Y: 2},
+		InSR:           &SpatialReference{WKID: 4326},
+		OutSR:          &SpatialReference{WKID: 102100, LatestWKID: 3857},
+	})
+	if err != nil {
+		t.Fatalf("failed to query layer: %v", err)
+	}
+
+	if len(results.Features) != 2 {
+		t.Fatalf("expected 2 features, got: %d", len(results.Features))
+	}
+	for i, f := range results.Features {
+		g, ok := f.Geometry.(GeometryPolygon)
+		if !ok {
+			t.Fatalf("expected GeometryPolygon, got: %T", f.Geometry)
+		}
+		if g.SpatialReference == nil || g.SpatialReference.WKID != 102100 || g.SpatialReference.LatestWKID != 3857 {
+			t.Errorf("expected feature %d to have spatial reference 102100 (3857), got: %v", i, g.SpatialReference)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package featureserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+type RelatedRecordsVariables struct {
+	// The object ids of the features to find the related records of
+	ObjectIDs []int
+	// The id of the relationship to follow, see the relationships of the layer info
+	RelationshipID int
+	// A comma delimited list of fields of the related records to return
+	OutFields []string
+	// A SQL where clause to filter the related records
+	DefinitionExpression string
+	// If true, the related records include their geometry. The default is false.
+	ReturnGeometry bool
+	// If true, z values are included in the geometries of the related records. The default is false.
+	ReturnZ bool
+	// If true, m values are included in the geometries of the related records. The default is false.
+	ReturnM bool
+	// The spatial reference of the returned geometry. If not set, the geometry is returned in the spatial reference of the related layer.
+	OutSR *SpatialReference
+}
+
+type RelatedRecordsResults struct {
+	// Can be one of:
+	//  - GeometryTypeNone
+	//  - GeometryTypePoint
+	//  - GeometryTypeMultiPoint
+	//  - GeometryTypePolyline
+	//  - GeometryTypePolygon
+	//  - GeometryTypeEnvelope
+	GeometryType     string            `json:"geometryType"`
+	HasZ             bool              `json:"hasZ"`
+	HasM             bool              `json:"hasM"`
+	SpatialReference *SpatialReference `json:"spatialReference"`
+	Fields           []Field           `json:"fields"`
+	// The related records of each source object id. Object ids without
+	// related records are left out.
+	RelatedRecords map[int][]Feature `json:"-"`
+	// True when there are more related records than were returned
+	ExceededTransferLimit bool `json:"exceededTransferLimit"`
+}
+
+// Returns the records related to the features through a relationship, grouped
+// by the object id of the feature they are related to. When the server returns
+// fewer records than there are ExceededTransferLimit is set, query fewer
+// object ids at a time to get the rest.
+func (l *Layer) QueryRelatedRecords(ctx context.Context, variables RelatedRecordsVariables) (results RelatedRecordsResults, err error) {
+	if len(variables.ObjectIDs) == 0 {
+		return results, fmt.Errorf("object ids must be set")
+	}
+
+	u, err := url.Parse(l.fs.url)
+	if err != nil {
+		return results, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	p, err := url.JoinPath(u.Path, fmt.Sprintf("%d", l.ID), "queryRelatedRecords")
+	if err != nil {
+		return results, fmt.Errorf("failed to join path: %w", err)
+	}
+
+	u.Path = p
+
+	formBody := &bytes.Buffer{}
+	formBodyWriter := multipart.NewWriter(formBody)
+
+	objectIDs := make([]string, len(variables.ObjectIDs))
+	for i, objectID := range variables.ObjectIDs {
+		objectIDs[i] = strconv.Itoa(objectID)
+	}
+	if err := formBodyWriter.WriteField("objectIds", strings.Join(objectIDs, ",")); err != nil {
+		return results, fmt.Errorf("failed to write 'objectIds' field: %w", err)
+	}
+
+	if err := formBodyWriter.WriteField("relationshipId", strconv.Itoa(variables.RelationshipID)); err != nil {
+		return results, fmt.Errorf("failed to write 'relationshipId' field: %w", err)
+	}
+
+	if variables.OutFields != nil {
+		if err := formBodyWriter.WriteField("outFields", strings.Join(variables.OutFields, ",")); err != nil {
+			return results, fmt.Errorf("failed to write 'outFields' field: %w", err)
+		}
+	}
+
+	if variables.DefinitionExpression != "" {
+		if err := formBodyWriter.WriteField("definitionExpression", variables.DefinitionExpression); err != nil {
+			return results, fmt.Errorf("failed to write 'definitionExpression' field: %w", err)
+		}
+	}
+
+	if err := formBodyWriter.WriteField("returnGeometry", fmt.Sprintf("%t", variables.ReturnGeometry)); err != nil {
+		return results, fmt.Errorf("failed to write 'returnGeometry' field: %w", err)
+	}
+
+	if variables.ReturnZ {
+		if err := formBodyWriter.WriteField("returnZ", "true"); err != nil {
+			return results, fmt.Errorf("failed to write 'returnZ' field: %w", err)
+		}
+	}
+
+	if variables.ReturnM {
+		if err := formBodyWriter.WriteField("returnM", "true"); err != nil {
+			return results, fmt.Errorf("failed to write 'returnM' field: %w", err)
+		}
+	}
+
+	if variables.OutSR != nil {
+		outSRJSON, err := json.Marshal(variables.OutSR)
+		if err != nil {
+			return results, fmt.Errorf("failed to marshal 'outSR' field: %w", err)
+		}
+		if err := formBodyWriter.WriteField("outSR", string(outSRJSON)); err != nil {
+			return results, fmt.Errorf("failed to write 'outSR' field: %w", err)
+		}
+	}
+
+	if err := formBodyWriter.WriteField("f", "json"); err != nil {
+		return results, fmt.Errorf("failed to write 'f' field: %w", err)
+	}
+
+	if err := formBodyWriter.Close(); err != nil {
+		return results, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), formBody)
+	if err != nil {
+		return results, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", formBodyWriter.FormDataContentType())
+
+	resp, err := l.fs.httpClient.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("failed to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return results, ErrNotFound
+		default:
+			return results, fmt.Errorf("unhandled status code: %d", resp.StatusCode)
+		}
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return results, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var respJSON map[string]interface{}
+	if err := json.Unmarshal(respBody, &respJSON); err != nil {
+		return results, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	respError, ok := respJSON["error"]
+	if ok {
+		var errRespErr ErrResponseError
+		if err := mapstructure.Decode(respError, &errRespErr); err != nil {
+			return results, fmt.Errorf("failed to decode error response: %w", err)
+		}
+		return results, errRespErr
+	}
+
+	var groups struct {
+		RelatedRecordGroups []struct {
+			ObjectID       int       `json:"objectId"`
+			RelatedRecords []Feature `json:"relatedRecords"`
+		} `json:"relatedRecordGroups"`
+	}
+	if err := json.Unmarshal(respBody, &groups); err != nil {
+		return results, fmt.Errorf("failed to decode related records groups: %w", err)
+	}
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return results, fmt.Errorf("failed to decode related records results: %w", err)
+	}
+
+	results.RelatedRecords = make(map[int][]Feature, len(groups.RelatedRecordGroups))
+	for _, group := range groups.RelatedRecordGroups {
+		for _, f := range group.RelatedRecords {
+			// Related tables have no geometry type
+			if !variables.ReturnGeometry || results.GeometryType == GeometryTypeNone {
+				f.Geometry = GeometryNone{}
+			} else {
+				geometry, err := decodeGeometry(results.GeometryType, results.HasZ, results.HasM, results.SpatialReference, f.Geometry)
+				if err != nil {
+					return results, fmt.Errorf("failed to decode related record of object id %d: %w", group.ObjectID, err)
+				}
+				f.Geometry = geometry
+			}
+			results.RelatedRecords[group.ObjectID] = append(results.RelatedRecords[group.ObjectID], f)
+		}
+	}
+
+	return results, nil
+}
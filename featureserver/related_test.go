@@ -0,0 +1,129 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLayerQueryRelatedRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0":
+			fmt.Fprint(w, `{
+				"id": 0,
+				"name": "Hydrants",
+				"type": "Feature Layer",
+				"relationships": [
+					{"id": 2, "name": "Inspections", "relatedTableId": 3, "cardinality": "esriRelCardinalityOneToMany", "role": "esriRelRoleOrigin", "keyField": "GlobalID", "composite": true}
+				]
+			}`)
+		case "/0/queryRelatedRecords":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("failed to parse form: %v", err)
+				return
+			}
+			expected := map[string]string{
+				"objectIds":            "1,2,3",
+				"relationshipId":       "2",
+				"outFields":            "status,inspected",
+				"definitionExpression": "status = 'failed'",
+				"returnGeometry":       "true",
+			}
+			for name, value := range expected {
+				if r.FormValue(name) != value {
+					t.Errorf("expected %s '%s', got: '%s'", name, value, r.FormValue(name))
+				}
+			}
+			fmt.Fprint(w, `{
+				"geometryType": "esriGeometryPoint",
+				"spatialReference": {"wkid": 4326},
+				"fields": [{"name": "status", "type": "esriFieldTypeString"}],
+				"relatedRecordGroups": [
+					{"objectId": 1, "relatedRecords": [
+						{"attributes": {"status": "failed"}, "geometry": {"x": 1, "y": 2}},
+						{"attributes": {"status": "failed"}, "geometry": {"x": 3, "y": 4}}
+					]},
+					{"objectId": 3, "relatedRecords": [
+						{"attributes": {"status": "failed"}, "geometry": {"x": 5, "y": 6}}
+					]}
+				],
+				"exceededTransferLimit": true
+			}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+	layer := fsc.Layer(0)
+
+	info, err := layer.Info(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get layer info: %v", err)
+	}
+	flInfo := info.(FeatureLayerInfo)
+	if len(flInfo.Relationships) != 1 {
+		t.Fatalf("expected 1 relationship, got: %d", len(flInfo.Relationships))
+	}
+	relationship := flInfo.Relationships[0]
+	if relationship.ID != 2 || relationship.RelatedTableID != 3 || relationship.Cardinality != RelationshipCardinalityOneToMany ||
+		relationship.Role != RelationshipRoleOrigin || relationship.KeyField != "GlobalID" || !relationship.Composite {
+		t.Errorf("unexpected relationship: %+v", relationship)
+	}
+
+	results, err := layer.QueryRelatedRecords(context.Background(), RelatedRecordsVariables{
+		ObjectIDs:            []int{1, 2, 3},
+		RelationshipID:       relationship.ID,
+		OutFields:            []string{"status", "inspected"},
+		DefinitionExpression: "status = 'failed'",
+		ReturnGeometry:       true,
+	})
+	if err != nil {
+		t.Fatalf("failed to query related records: %v", err)
+	}
+
+	if len(results.RelatedRecords) != 2 {
+		t.Fatalf("expected 2 groups, got: %d", len(results.RelatedRecords))
+	}
+	if !results.ExceededTransferLimit {
+		t.Errorf("expected exceeded transfer limit")
+	}
+	if len(results.RelatedRecords[1]) != 2 || len(results.RelatedRecords[3]) != 1 {
+		t.Errorf("expected 2 records for object id 1 and 1 for object id 3, got: %d and %d", len(results.RelatedRecords[1]), len(results.RelatedRecords[3]))
+	}
+	if _, ok := results.RelatedRecords[2]; ok {
+		t.Errorf("expected no group for object id 2")
+	}
+
+	record := results.RelatedRecords[3][0]
+	point, ok := record.Geometry.(GeometryPoint)
+	if !ok {
+		t.Fatalf("expected GeometryPoint, got: %T", record.Geometry)
+	}
+	if point.X != 5 || point.Y != 6 || point.SpatialReference == nil || point.SpatialReference.WKID != 4326 {
+		t.Errorf("unexpected geometry: %+v", point)
+	}
+
+	var attributes struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(record.Attributes, &attributes); err != nil {
+		t.Fatalf("failed to unmarshal attributes: %v", err)
+	}
+	if attributes.Status != "failed" {
+		t.Errorf("expected status 'failed', got: '%s'", attributes.Status)
+	}
+
+	// Rejected before a request is sent
+	if _, err := layer.QueryRelatedRecords(context.Background(), RelatedRecordsVariables{RelationshipID: relationship.ID}); err == nil {
+		t.Errorf("expected error for missing object ids")
+	}
+}
@@ -0,0 +1,16 @@
+package featureserver
+
+// Identifies the coordinate system of a geometry. Either a well-known ID or a
+// well-known text is set.
+type SpatialReference struct {
+	// Well-known ID of the coordinate system
+	WKID int `json:"wkid,omitempty"`
+	// Most recent well-known ID of the coordinate system
+	LatestWKID int `json:"latestWkid,omitempty"`
+	// Well-known text of the coordinate system, only set when it has no well-known ID
+	WKT string `json:"wkt,omitempty"`
+	// Well-known ID of the vertical coordinate system
+	VCSWKID int `json:"vcsWkid,omitempty"`
+	// Most recent well-known ID of the vertical coordinate system
+	LatestVCSWKID int `json:"latestVcsWkid,omitempty"`
+}
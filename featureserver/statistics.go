@@ -0,0 +1,47 @@
+package featureserver
+
+const (
+	StatisticTypeCount = "count"
+	StatisticTypeSum   = "sum"
+	StatisticTypeMin   = "min"
+	StatisticTypeMax   = "max"
+	StatisticTypeAvg   = "avg"
+	// Standard deviation
+	StatisticTypeStddev = "stddev"
+	// Variance
+	StatisticTypeVar = "var"
+	// Continuous percentile, interpolated between values
+	StatisticTypePercentileCont = "percentile_cont"
+	// Discrete percentile, always one of the values
+	StatisticTypePercentileDisc = "percentile_disc"
+)
+
+type OutStatistic struct {
+	// Can be one of:
+	//  - StatisticTypeCount
+	//  - StatisticTypeSum
+	//  - StatisticTypeMin
+	//  - StatisticTypeMax
+	//  - StatisticTypeAvg
+	//  - StatisticTypeStddev
+	//  - StatisticTypeVar
+	//  - StatisticTypePercentileCont
+	//  - StatisticTypePercentileDisc
+	StatisticType string `json:"statisticType"`
+	// The field the statistic is computed on
+	OnStatisticField string `json:"onStatisticField"`
+	// The attribute name of the statistic in the results. If not set, the server picks one.
+	OutStatisticFieldName string `json:"outStatisticFieldName,omitempty"`
+	// Only used by StatisticTypePercentileCont and StatisticTypePercentileDisc
+	StatisticParameters *StatisticParameters `json:"statisticParameters,omitempty"`
+}
+
+type StatisticParameters struct {
+	// The percentile to compute, between 0 and 1
+	Value float64 `json:"value"`
+	// Can be one of:
+	//  - OrderAsc
+	//  - OrderDesc
+	// The default is OrderAsc.
+	OrderBy string `json:"orderBy,omitempty"`
+}
@@ -0,0 +1,149 @@
+package featureserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Decodes a query response token by token. Each feature is passed to fn as it
+// is decoded and every other member of the response is decoded in to the
+// results. An error response is returned as ErrResponseError.
+func decodeQueryResults(r io.Reader, variables QueryVariables, fn func(f Feature) error) (results QueryResults, err error) {
+	returnGeometry := variables.ReturnGeometry
+
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return results, fmt.Errorf("failed to decode query results: %w", err)
+	}
+
+	// Geometries can only be decoded once every member of the response they
+	// depend on is known. The server writes them before the features but in
+	// case it doesn't, the features are held back until they have all been
+	// seen or the response ends. Members the query can't return aren't waited
+	// for. A member that never comes, like hasZ when ReturnZ is set on a layer
+	// without z values, makes every feature wait for the end of the response,
+	// so they are all held in memory like a non streaming decode.
+	var pending []Feature
+	awaiting := map[string]bool{"geometryType": true, "spatialReference": true}
+	if variables.ReturnZ {
+		awaiting["hasZ"] = true
+	}
+	if variables.ReturnM {
+		awaiting["hasM"] = true
+	}
+	if variables.QuantizationParameters != nil {
+		awaiting["transform"] = true
+	}
+
+	emit := func(f Feature) error {
+		if !returnGeometry {
+			f.Geometry = GeometryNone{}
+			return fn(f)
+		}
+		geometry, err := decodeGeometry(results.GeometryType, results.HasZ, results.HasM, results.SpatialReference, f.Geometry)
+		if err != nil {
+			return err
+		}
+		if results.Transform != nil {
+			geometry = dequantizeGeometry(geometry, *results.Transform)
+		}
+		f.Geometry = geometry
+		return fn(f)
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return results, fmt.Errorf("failed to decode query results: %w", err)
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return results, fmt.Errorf("failed to decode query results: unexpected token %v", token)
+		}
+
+		switch key {
+		case "error":
+			var errRespErr ErrResponseError
+			if err := dec.Decode(&errRespErr); err != nil {
+				return results, fmt.Errorf("failed to decode error response: %w", err)
+			}
+			return results, errRespErr
+		case "features":
+			token, err := dec.Token()
+			if err != nil {
+				return results, fmt.Errorf("failed to decode features: %w", err)
+			}
+			if token == nil {
+				continue
+			}
+			if d, ok := token.(json.Delim); !ok || d != '[' {
+				return results, fmt.Errorf("failed to decode features: expected '[' but got %v", token)
+			}
+			for dec.More() {
+				var f Feature
+				if err := dec.Decode(&f); err != nil {
+					return results, fmt.Errorf("failed to decode feature: %w", err)
+				}
+				if returnGeometry && len(awaiting) > 0 {
+					pending = append(pending, f)
+					continue
+				}
+				if err := emit(f); err != nil {
+					return results, err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return results, fmt.Errorf("failed to decode features: %w", err)
+			}
+		default:
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return results, fmt.Errorf("failed to decode '%s': %w", key, err)
+			}
+			member, err := json.Marshal(map[string]json.RawMessage{key: value})
+			if err != nil {
+				return results, fmt.Errorf("failed to decode '%s': %w", key, err)
+			}
+			if err := json.Unmarshal(member, &results); err != nil {
+				return results, fmt.Errorf("failed to decode '%s': %w", key, err)
+			}
+			if awaiting[key] {
+				delete(awaiting, key)
+				if len(awaiting) == 0 {
+					for _, f := range pending {
+						if err := emit(f); err != nil {
+							return results, err
+						}
+					}
+					pending = nil
+				}
+			}
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return results, fmt.Errorf("failed to decode query results: %w", err)
+	}
+
+	for _, f := range pending {
+		if err := emit(f); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected '%s' but got %v", delim, token)
+	}
+	return nil
+}
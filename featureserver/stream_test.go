@@ -0,0 +1,284 @@
+package featureserver
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDecodeQueryResults(t *testing.T) {
+	t.Run("Streams features", func(t *testing.T) {
+		body := `{"objectIdFieldName":"objectid","geometryType":"esriGeometryPoint","spatialReference":{"wkid":3857},"fields":[{"name":"objectid","type":"esriFieldTypeOID"}],"features":[{"attributes":{"objectid":1},"geometry":{"x":1,"y":2}},{"attributes":{"objectid":2},"geometry":{"x":3,"y":4}}],"exceededTransferLimit":true}`
+
+		var features []Feature
+		results, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true}, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to decode query results: %v", err)
+		}
+
+		if results.ObjectIDFieldName != "objectid" {
+			t.Errorf("expected object id field name objectid, got: %s", results.ObjectIDFieldName)
+		}
+		if !results.ExceededTransferLimit {
+			t.Errorf("expected exceeded transfer limit")
+		}
+		if len(results.Fields) != 1 {
+			t.Errorf("expected 1 field, got: %d", len(results.Fields))
+		}
+		if len(results.Features) != 0 {
+			t.Errorf("expected no features in results, got: %d", len(results.Features))
+		}
+		if len(features) != 2 {
+			t.Fatalf("expected 2 features, got: %d", len(features))
+		}
+
+		g, ok := features[1].Geometry.(GeometryPoint)
+		if !ok {
+			t.Fatalf("expected point geometry, got: %T", features[1].Geometry)
+		}
+		if g.X != 3 || g.Y != 4 {
+			t.Errorf("expected point 3,4, got: %f,%f", g.X, g.Y)
+		}
+		if g.SpatialReference == nil || g.SpatialReference.WKID != 3857 {
+			t.Errorf("expected spatial reference 3857, got: %v", g.SpatialReference)
+		}
+	})
+
+	t.Run("Features before geometry type", func(t *testing.T) {
+		body := `{"features":[{"attributes":{},"geometry":{"paths":[[[1,2],[3,4]]]}}],"geometryType":"esriGeometryPolyline"}`
+
+		var features []Feature
+		if _, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true}, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to decode query results: %v", err)
+		}
+
+		if len(features) != 1 {
+			t.Fatalf("expected 1 feature, got: %d", len(features))
+		}
+		if _, ok := features[0].Geometry.(GeometryPolyline); !ok {
+			t.Errorf("expected polyline geometry, got: %T", features[0].Geometry)
+		}
+	})
+
+	t.Run("Features before header", func(t *testing.T) {
+		body := `{"geometryType":"esriGeometryPolyline","features":[{"attributes":{},"geometry":{"paths":[[[2,4,7],[2,-2,8]]]}}],"hasZ":true,"transform":{"originPosition":"upperLeft","scale":[0.5,0.5],"translate":[100,200]},"spatialReference":{"wkid":3857}}`
+
+		var features []Feature
+		if _, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true, ReturnZ: true, QuantizationParameters: &QuantizationParameters{}}, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to decode query results: %v", err)
+		}
+
+		if len(features) != 1 {
+			t.Fatalf("expected 1 feature, got: %d", len(features))
+		}
+		g, ok := features[0].Geometry.(GeometryPolyline)
+		if !ok {
+			t.Fatalf("expected polyline geometry, got: %T", features[0].Geometry)
+		}
+		if g.SpatialReference == nil || g.SpatialReference.WKID != 3857 {
+			t.Errorf("expected spatial reference 3857, got: %v", g.SpatialReference)
+		}
+
+		expect := [][3]float64{{101, 198, 7}, {102, 199, 8}}
+		for i, c := range expect {
+			got := g.Paths[0][i]
+			if got.X != c[0] || got.Y != c[1] || got.Z == nil || *got.Z != c[2] {
+				t.Errorf("expected point %d to be %v, got: %v,%v,%v", i, c, got.X, got.Y, got.Z)
+			}
+		}
+	})
+
+	t.Run("Missing header member", func(t *testing.T) {
+		// A layer without z values queried with ReturnZ has no hasZ
+		body := `{"geometryType":"esriGeometryPoint","spatialReference":{"wkid":4326},"features":[{"attributes":{},"geometry":{"x":1,"y":2}},{"attributes":{},"geometry":{"x":3,"y":4}}],"exceededTransferLimit":false}`
+
+		var features []Feature
+		results, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true, ReturnZ: true}, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to decode query results: %v", err)
+		}
+
+		// The features are held back until the end of the response
+		if len(features) != 2 {
+			t.Fatalf("expected 2 features, got: %d", len(features))
+		}
+		g, ok := features[1].Geometry.(GeometryPoint)
+		if !ok || g.X != 3 || g.Y != 4 || g.Z != nil {
+			t.Errorf("expected point 3,4 without z value, got: %+v", features[1].Geometry)
+		}
+		if results.HasZ {
+			t.Errorf("expected no z values")
+		}
+	})
+
+	t.Run("Quantized geometries", func(t *testing.T) {
+		body := `{"geometryType":"esriGeometryPolyline","transform":{"originPosition":"upperLeft","scale":[0.5,0.5],"translate":[100,200]},"features":[{"attributes":{},"geometry":{"paths":[[[2,4],[2,-2]],[[10,10],[-4,0]]]}}]}`
+
+		var features []Feature
+		if _, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true}, func(f Feature) error {
+			features = append(features, f)
+			return nil
+		}); err != nil {
+			t.Fatalf("failed to decode query results: %v", err)
+		}
+
+		g, ok := features[0].Geometry.(GeometryPolyline)
+		if !ok {
+			t.Fatalf("expected polyline geometry, got: %T", features[0].Geometry)
+		}
+
+		expect := [][][2]float64{
+			{{101, 198}, {102, 199}},
+			{{105, 195}, {103, 195}},
+		}
+		for i, path := range expect {
+			for j, c := range path {
+				if got := g.Paths[i][j]; got.X != c[0] || got.Y != c[1] {
+					t.Errorf("expected path %d point %d to be %v, got: %v,%v", i, j, c, got.X, got.Y)
+				}
+			}
+		}
+	})
+
+	t.Run("True curves", func(t *testing.T) {
+		decode := func(t *testing.T, geometry string) (GeometryPolygon, error) {
+			body := `{"geometryType":"esriGeometryPolygon","features":[{"attributes":{},"geometry":` + geometry + `}]}`
+			var features []Feature
+			if _, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true}, func(f Feature) error {
+				features = append(features, f)
+				return nil
+			}); err != nil {
+				return GeometryPolygon{}, err
+			}
+			g, ok := features[0].Geometry.(GeometryPolygon)
+			if !ok {
+				t.Fatalf("expected polygon geometry, got: %T", features[0].Geometry)
+			}
+			return g, nil
+		}
+
+		onCircle := func(t *testing.T, points []Coordinate, cx, cy, r float64) {
+			for _, p := range points {
+				if d := math.Hypot(p.X-cx, p.Y-cy); math.Abs(d-r) > 1e-9 {
+					t.Errorf("expected point %v,%v to be %v from %v,%v, got: %v", p.X, p.Y, r, cx, cy, d)
+				}
+			}
+		}
+
+		t.Run("Arcs", func(t *testing.T) {
+			// A half circle to the left through (-5, 5) and a clockwise half circle around (5, 5)
+			g, err := decode(t, `{"curveRings":[[[0,0],{"c":[[0,10],[-5,5]]},[10,10],{"a":[[0,0],[5,5],0,1]}]]}`)
+			if err != nil {
+				t.Fatalf("failed to decode query results: %v", err)
+			}
+			ring := g.Rings[0]
+
+			// Each half circle is densified in to 180 / 5 segments
+			if len(ring) != 1+36+1+36 {
+				t.Fatalf("expected %d points, got: %d", 1+36+1+36, len(ring))
+			}
+			onCircle(t, ring[1:37], 0, 5, 5)
+			if ring[18].X != -5 || math.Abs(ring[18].Y-5) > 1e-9 {
+				t.Errorf("expected the arc to pass through -5,5, got: %v,%v", ring[18].X, ring[18].Y)
+			}
+			if ring[36].X != 0 || ring[36].Y != 10 {
+				t.Errorf("expected curve end point 0,10, got: %v,%v", ring[36].X, ring[36].Y)
+			}
+			onCircle(t, ring[37:], 5, 5, math.Hypot(5, 5))
+			// Clockwise from (10, 10) around (5, 5) passes above the center
+			if mid := ring[37+18]; mid.X < 10 {
+				t.Errorf("expected clockwise arc to pass through x > 10, got: %v,%v", mid.X, mid.Y)
+			}
+		})
+
+		t.Run("Full circle", func(t *testing.T) {
+			g, err := decode(t, `{"hasZ":true,"curveRings":[[[0,0,1],{"c":[[0,0,1],[10,0]]}]]}`)
+			if err != nil {
+				t.Fatalf("failed to decode query results: %v", err)
+			}
+			ring := g.Rings[0]
+			if len(ring) != 1+72 {
+				t.Fatalf("expected %d points, got: %d", 1+72, len(ring))
+			}
+			onCircle(t, ring, 5, 0, 5)
+			// Clockwise like an exterior ring
+			if area := ringArea(ring); area > -70 {
+				t.Errorf("expected a clockwise circle with an area close to -78.5, got: %v", area)
+			}
+			if ring[10].Z == nil || *ring[10].Z != 1 {
+				t.Errorf("expected interpolated z value 1, got: %v", ring[10].Z)
+			}
+		})
+
+		t.Run("Bezier", func(t *testing.T) {
+			body := `{"geometryType":"esriGeometryPolyline","features":[{"attributes":{},"geometry":{"curvePaths":[[[0,0],{"b":[[10,0],[0,10],[10,10]]}]]}}]}`
+			var features []Feature
+			if _, err := decodeQueryResults(strings.NewReader(body), QueryVariables{ReturnGeometry: true}, func(f Feature) error {
+				features = append(features, f)
+				return nil
+			}); err != nil {
+				t.Fatalf("failed to decode query results: %v", err)
+			}
+			path := features[0].Geometry.(GeometryPolyline).Paths[0]
+			if len(path) != 1+16 {
+				t.Fatalf("expected %d points, got: %d", 1+16, len(path))
+			}
+			if mid := path[8]; mid.X != 5 || mid.Y != 7.5 {
+				t.Errorf("expected bezier mid point 5,7.5, got: %v,%v", mid.X, mid.Y)
+			}
+		})
+
+		t.Run("Unknown curve type", func(t *testing.T) {
+			if _, err := decode(t, `{"curveRings":[[[0,0],{"x":[[0,10]]}]]}`); err == nil {
+				t.Errorf("expected error for unknown curve type")
+			}
+		})
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		body := `{"error":{"code":400,"message":"Unable to complete operation.","details":["Invalid query"]}}`
+
+		_, err := decodeQueryResults(strings.NewReader(body), QueryVariables{}, func(f Feature) error {
+			t.Errorf("expected no features")
+			return nil
+		})
+
+		var errRespErr ErrResponseError
+		if !errors.As(err, &errRespErr) {
+			t.Fatalf("expected ErrResponseError, got: %v", err)
+		}
+		if errRespErr.Code != 400 || len(errRespErr.Details) != 1 {
+			t.Errorf("expected code 400 with 1 detail, got: %+v", errRespErr)
+		}
+	})
+
+	t.Run("Callback error stops decoding", func(t *testing.T) {
+		body := `{"features":[{"attributes":{}},{"attributes":{}}]}`
+		stop := errors.New("stop")
+
+		calls := 0
+		_, err := decodeQueryResults(strings.NewReader(body), QueryVariables{}, func(f Feature) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("expected stop error, got: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got: %d", calls)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package featureserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// A time range, either end can be nil to leave it open
+type TimeExtent struct {
+	Start *time.Time
+	End   *time.Time
+}
+
+// Arcgis encodes time extents as [start, end] in unix milliseconds with null
+// for an open end
+func (t TimeExtent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]*int64{unixMillis(t.Start), unixMillis(t.End)})
+}
+
+func (t *TimeExtent) UnmarshalJSON(b []byte) error {
+	var millis []*int64
+	if err := json.Unmarshal(b, &millis); err != nil {
+		return err
+	}
+	extent, err := timeExtentFromMillis(millis)
+	if err != nil {
+		return err
+	}
+	*t = extent
+	return nil
+}
+
+// The value of the time query parameter, "<start>,<end>" in unix milliseconds
+// with "null" for an open end
+func (t TimeExtent) queryValue() string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return "null"
+		}
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return fmt.Sprintf("%s,%s", format(t.Start), format(t.End))
+}
+
+type TimeReference struct {
+	TimeZone               string `json:"timeZone"`
+	RespectsDaylightSaving bool   `json:"respectsDaylightSaving"`
+}
+
+type TimeInfo struct {
+	// Name of the field with the start time of each feature
+	StartTimeField string `json:"startTimeField"`
+	// Name of the field with the end time of each feature, empty when features are instants
+	EndTimeField string `json:"endTimeField"`
+	// Name of the field identifying the track of each feature
+	TrackIDField string `json:"trackIdField"`
+	// Time range of all the features in the layer
+	TimeExtent TimeExtent `json:"timeExtent"`
+	// Time zone of the time values, nil when the values are in UTC
+	TimeReference *TimeReference `json:"timeReference"`
+}
+
+func unixMillis(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	millis := t.UnixMilli()
+	return &millis
+}
+
+func timeExtentFromMillis(millis []*int64) (t TimeExtent, err error) {
+	if len(millis) != 2 {
+		return t, fmt.Errorf("time extent must have 2 values but has %d", len(millis))
+	}
+	if millis[0] != nil {
+		start := time.UnixMilli(*millis[0])
+		t.Start = &start
+	}
+	if millis[1] != nil {
+		end := time.UnixMilli(*millis[1])
+		t.End = &end
+	}
+	return t, nil
+}
+
+// Decodes the [start, end] array of a time extent when decoding with mapstructure
+func timeExtentDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(TimeExtent{}) {
+		return data, nil
+	}
+	raw, ok := data.([]interface{})
+	if !ok {
+		return data, nil
+	}
+	millis := make([]*int64, len(raw))
+	for i, r := range raw {
+		if r == nil {
+			continue
+		}
+		v, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("time extent value must be a number but got %T", r)
+		}
+		m := int64(v)
+		millis[i] = &m
+	}
+	return timeExtentFromMillis(millis)
+}
@@ -0,0 +1,102 @@
+package featureserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeExtent(t *testing.T) {
+	start := time.UnixMilli(1199145600000)
+	end := time.UnixMilli(1230768000000)
+
+	tests := []struct {
+		Name     string
+		Extent   TimeExtent
+		Expected string
+	}{
+		{Name: "Closed", Extent: TimeExtent{Start: &start, End: &end}, Expected: "1199145600000,1230768000000"},
+		{Name: "Open start", Extent: TimeExtent{End: &end}, Expected: "null,1230768000000"},
+		{Name: "Open end", Extent: TimeExtent{Start: &start}, Expected: "1199145600000,null"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var timeField string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseMultipartForm(1 << 20); err != nil {
+					t.Errorf("failed to parse form: %v", err)
+					return
+				}
+				timeField = r.FormValue("time")
+				fmt.Fprint(w, `{"features":[]}`)
+			}))
+			defer srv.Close()
+
+			fsc, err := NewClient(srv.URL)
+			if err != nil {
+				t.Fatalf("failed to create feature server client: %v", err)
+			}
+
+			if _, err := fsc.Layer(0).Query(context.Background(), QueryVariables{Where: "1=1", Time: &test.Extent}); err != nil {
+				t.Fatalf("failed to query layer: %v", err)
+			}
+			if timeField != test.Expected {
+				t.Errorf("expected time '%s', got: '%s'", test.Expected, timeField)
+			}
+		})
+	}
+}
+
+func TestLayerInfoTimeInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"id": 0,
+			"name": "Fires",
+			"type": "Feature Layer",
+			"geometryType": "esriGeometryPoint",
+			"timeInfo": {
+				"startTimeField": "start_date",
+				"endTimeField": "end_date",
+				"timeExtent": [1199145600000, null],
+				"timeReference": {"timeZone": "Pacific Standard Time", "respectsDaylightSaving": true}
+			}
+		}`)
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	info, err := fsc.Layer(0).Info(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get layer info: %v", err)
+	}
+	flInfo, ok := info.(FeatureLayerInfo)
+	if !ok {
+		t.Fatalf("expected FeatureLayerInfo, got: %T", info)
+	}
+	if flInfo.TimeInfo == nil {
+		t.Fatalf("expected time info")
+	}
+
+	timeInfo := flInfo.TimeInfo
+	if timeInfo.StartTimeField != "start_date" || timeInfo.EndTimeField != "end_date" {
+		t.Errorf("expected time fields 'start_date' and 'end_date', got: '%s' and '%s'", timeInfo.StartTimeField, timeInfo.EndTimeField)
+	}
+	if timeInfo.TimeExtent.Start == nil || timeInfo.TimeExtent.Start.UnixMilli() != 1199145600000 {
+		t.Errorf("expected time extent start 1199145600000, got: %v", timeInfo.TimeExtent.Start)
+	}
+	if timeInfo.TimeExtent.End != nil {
+		t.Errorf("expected open time extent end, got: %v", timeInfo.TimeExtent.End)
+	}
+	if timeInfo.TimeReference == nil || timeInfo.TimeReference.TimeZone != "Pacific Standard Time" || !timeInfo.TimeReference.RespectsDaylightSaving {
+		t.Errorf("expected time reference 'Pacific Standard Time' respecting daylight saving, got: %+v", timeInfo.TimeReference)
+	}
+}
@@ -0,0 +1,89 @@
+package featureserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// A feature with its attributes decoded in to A and its geometry in to G. G
+// should be one of the geometry types or interface{} to accept any of them.
+type TypedFeature[A, G any] struct {
+	Attributes A `json:"attributes"`
+	Geometry   G `json:"geometry"`
+}
+
+type QueryTypedOption = func(*queryTypedConfig) error
+
+type queryTypedConfig struct {
+	validate bool
+	info     Info
+}
+
+// Runs ValidateFeature on TypedFeature[A, G] before querying. If info is nil
+// the layer info is fetched from the server.
+func WithValidation(info Info) QueryTypedOption {
+	return func(c *queryTypedConfig) error {
+		c.validate = true
+		c.info = info
+		return nil
+	}
+}
+
+// Queries the layer and decodes the attributes of each feature in to A and
+// its geometry in to G.
+func QueryTyped[A, G any](ctx context.Context, l *Layer, variables QueryVariables, opts ...QueryTypedOption) ([]TypedFeature[A, G], error) {
+	var config queryTypedConfig
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.validate {
+		info := config.info
+		if info == nil {
+			var err error
+			info, err = l.Info(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get layer info: %w", err)
+			}
+		}
+		if err := ValidateFeature(TypedFeature[A, G]{}, info); err != nil {
+			return nil, fmt.Errorf("failed to validate feature: %w", err)
+		}
+	}
+
+	results, err := l.Query(ctx, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	features := make([]TypedFeature[A, G], len(results.Features))
+	for i, f := range results.Features {
+		feature, err := decodeTypedFeature[A, G](f)
+		if err != nil {
+			return nil, err
+		}
+		features[i] = feature
+	}
+
+	return features, nil
+}
+
+func decodeTypedFeature[A, G any](f Feature) (feature TypedFeature[A, G], err error) {
+	if err := json.Unmarshal(f.Attributes, &feature.Attributes); err != nil {
+		return feature, fmt.Errorf("failed to unmarshal attributes: %w", err)
+	}
+
+	switch g := f.Geometry.(type) {
+	case G:
+		feature.Geometry = g
+	case nil, GeometryNone:
+		// Leave the geometry as its zero value when it wasn't requested
+	default:
+		return feature, fmt.Errorf("expected geometry '%T' but got '%T'", feature.Geometry, f.Geometry)
+	}
+
+	return feature, nil
+}
@@ -0,0 +1,82 @@
+package featureserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryTyped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0":
+			fmt.Fprint(w, `{"id":0,"name":"points","type":"Feature Layer","geometryType":"esriGeometryPoint","fields":[{"name":"objectid","type":"esriFieldTypeOID"},{"name":"rotation","type":"esriFieldTypeSmallInteger","nullable":true}]}`)
+		case "/0/query":
+			fmt.Fprint(w, `{"geometryType":"esriGeometryPoint","spatialReference":{"wkid":4326},"features":[{"attributes":{"objectid":1,"rotation":null},"geometry":{"x":1,"y":2}},{"attributes":{"objectid":2,"rotation":45},"geometry":{"x":3,"y":4}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	fsc, err := NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to create feature server client: %v", err)
+	}
+
+	type Attributes struct {
+		ObjectID int32  `json:"objectid"`
+		Rotation *int16 `json:"rotation"`
+	}
+
+	t.Run("Decodes attributes and geometry", func(t *testing.T) {
+		features, err := QueryTyped[Attributes, GeometryPoint](context.Background(), fsc.Layer(0), QueryVariables{Where: "1=1", ReturnGeometry: true}, WithValidation(nil))
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		if len(features) != 2 {
+			t.Fatalf("expected 2 features, got: %d", len(features))
+		}
+
+		if features[0].Attributes.ObjectID != 1 || features[0].Attributes.Rotation != nil {
+			t.Errorf("expected attributes {1 nil}, got: %+v", features[0].Attributes)
+		}
+
+		if features[1].Attributes.Rotation == nil || *features[1].Attributes.Rotation != 45 {
+			t.Errorf("expected rotation 45, got: %v", features[1].Attributes.Rotation)
+		}
+
+		if features[1].Geometry.X != 3 || features[1].Geometry.Y != 4 {
+			t.Errorf("expected geometry 3,4, got: %+v", features[1].Geometry)
+		}
+	})
+
+	t.Run("Validation fails for mismatched geometry", func(t *testing.T) {
+		_, err := QueryTyped[Attributes, GeometryPolygon](context.Background(), fsc.Layer(0), QueryVariables{Where: "1=1", ReturnGeometry: true}, WithValidation(nil))
+		if err == nil {
+			t.Errorf("expected validation error, got none")
+		}
+	})
+
+	t.Run("Interface geometry accepts any geometry type", func(t *testing.T) {
+		features, err := QueryTyped[Attributes, interface{}](context.Background(), fsc.Layer(0), QueryVariables{Where: "1=1", ReturnGeometry: true}, WithValidation(nil))
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		if len(features) != 2 {
+			t.Fatalf("expected 2 features, got: %d", len(features))
+		}
+
+		point, ok := features[0].Geometry.(GeometryPoint)
+		if !ok {
+			t.Fatalf("expected GeometryPoint, got: %T", features[0].Geometry)
+		}
+		if point.X != 1 || point.Y != 2 {
+			t.Errorf("expected geometry 1,2, got: %+v", point)
+		}
+	})
+}
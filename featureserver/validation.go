@@ -1,6 +1,7 @@
 package featureserver
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -44,11 +45,13 @@ func ValidateFeature(feature interface{}, info Info) error {
 
 	var fields []FieldInfo
 	expectGeometryType := GeometryTypeNone
+	expectZ, expectM := false, false
 
 	switch info := info.(type) {
 	case FeatureLayerInfo:
 		fields = info.Fields
 		expectGeometryType = info.GeometryType
+		expectZ, expectM = info.HasZ, info.HasM
 	case TableInfo:
 		fields = info.Fields
 	default:
@@ -57,26 +60,62 @@ func ValidateFeature(feature interface{}, info Info) error {
 
 	// Validate geometry
 
-	if geometry.Type.Kind() != reflect.Struct {
-		return fmt.Errorf("geometry must be a struct but is %s", geometry.Type.Kind())
-	}
-
-	geometryType := geometry.Type.String()
-	switch expectGeometryType {
-	case GeometryTypeNone:
-		if geometryType != "featureserver.GeometryNone" {
-			return fmt.Errorf("geometry type is none but geometry is not")
+	// An interface geometry accepts any geometry type, only the z and m values
+	// of the geometry it holds are checked
+	if geometry.Type.Kind() != reflect.Interface {
+		if geometry.Type.Kind() != reflect.Struct {
+			return fmt.Errorf("geometry must be a struct but is %s", geometry.Type.Kind())
 		}
-	case GeometryTypePoint:
-		if geometryType != "featureserver.GeometryPoint" {
-			return fmt.Errorf("geometry type is point but geometry is not")
+
+		geometryType := geometry.Type.String()
+		switch expectGeometryType {
+		case GeometryTypeNone:
+			if geometryType != "featureserver.GeometryNone" {
+				return fmt.Errorf("geometry type is none but geometry is not")
+			}
+		case GeometryTypePoint:
+			if geometryType != "featureserver.GeometryPoint" {
+				return fmt.Errorf("geometry type is point but geometry is not")
+			}
+		case GeometryTypeMultiPoint:
+			if geometryType != "featureserver.GeometryMultiPoint" {
+				return fmt.Errorf("invalid geometry, expected '%s' but got '%s'", expectGeometryType, geometryType)
+			}
+		case GeometryTypePolyline:
+			if geometryType != "featureserver.GeometryPolyline" {
+				return fmt.Errorf("invalid geometry, expected '%s' but got '%s'", expectGeometryType, geometryType)
+			}
+		case GeometryTypePolygon:
+			if geometryType != "featureserver.GeometryPolygon" {
+				return fmt.Errorf("invalid geometry, expected '%s' but got '%s'", expectGeometryType, geometryType)
+			}
+		case GeometryTypeEnvelope:
+			if geometryType != "featureserver.GeometryEnvelope" {
+				return fmt.Errorf("invalid geometry, expected '%s' but got '%s'", expectGeometryType, geometryType)
+			}
+		default:
+			return fmt.Errorf("unhandled geometry type: %s", geometryType)
 		}
-	case GeometryTypeMultiPoint:
-		if geometryType != "featureserver.GeometryMultiPoint" {
-			return fmt.Errorf("invalid geometry, expected '%s' but got '%s'", expectGeometryType, geometryType)
+	}
+
+	// Only geometries with coordinates can be checked for z and m values, a zero
+	// value feature is only validated by its type.
+	geometryValue := reflect.ValueOf(feature).FieldByName(geometry.Name)
+	if geometryValue.CanInterface() {
+		if hasZ, hasM, ok := geometryDimensions(geometryValue.Interface()); ok {
+			if hasZ && !expectZ {
+				return fmt.Errorf("geometry has z values but layer does not")
+			}
+			if !hasZ && expectZ {
+				return fmt.Errorf("layer has z values but geometry does not")
+			}
+			if hasM && !expectM {
+				return fmt.Errorf("geometry has m values but layer does not")
+			}
+			if !hasM && expectM {
+				return fmt.Errorf("layer has m values but geometry does not")
+			}
 		}
-	default:
-		return fmt.Errorf("unhandled geometry type: %s", geometryType)
 	}
 
 	// Validate attributes
@@ -136,3 +175,291 @@ func ValidateFeature(feature interface{}, info Info) error {
 
 	return nil
 }
+
+// Reports whether a geometry has z and m values. ok is false if the geometry
+// has no coordinates to check.
+func geometryDimensions(geometry interface{}) (hasZ bool, hasM bool, ok bool) {
+	coordinates := func(cs []Coordinate) {
+		for _, c := range cs {
+			ok = true
+			hasZ = hasZ || c.Z != nil
+			hasM = hasM || c.M != nil
+		}
+	}
+
+	switch g := geometry.(type) {
+	case GeometryPoint:
+		if g.X == 0 && g.Y == 0 && g.Z == nil && g.M == nil {
+			return false, false, false
+		}
+		return g.Z != nil, g.M != nil, true
+	case GeometryMultiPoint:
+		coordinates(g.Points)
+		return hasZ, hasM, ok
+	case GeometryPolyline:
+		for _, path := range g.Paths {
+			coordinates(path)
+		}
+		return hasZ, hasM, ok
+	case GeometryPolygon:
+		for _, ring := range g.Rings {
+			coordinates(ring)
+		}
+		return hasZ, hasM, ok
+	case GeometryEnvelope:
+		if g == (GeometryEnvelope{}) {
+			return false, false, false
+		}
+		return g.ZMin != nil || g.ZMax != nil, g.MMin != nil || g.MMax != nil, true
+	default:
+		return false, false, false
+	}
+}
+
+// Checks the where clause and out fields of a query against the fields of a
+// layer. Reports unknown fields and literals compared with fields of another
+// type. Parts of the where clause that can't be checked, like the results of
+// functions, are skipped.
+func ValidateQuery(variables QueryVariables, info Info) error {
+	var fields []FieldInfo
+
+	switch info := info.(type) {
+	case FeatureLayerInfo:
+		fields = info.Fields
+	case TableInfo:
+		fields = info.Fields
+	default:
+		return fmt.Errorf("unhandled info type: %T", info)
+	}
+
+	fieldsByName := make(map[string]FieldInfo, len(fields))
+	for _, field := range fields {
+		fieldsByName[strings.ToLower(field.Name)] = field
+	}
+
+	// Field names are case insensitive and may be qualified with a table name
+	lookupField := func(name string) (FieldInfo, bool) {
+		name = strings.ToLower(name)
+		if field, ok := fieldsByName[name]; ok {
+			return field, true
+		}
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			field, ok := fieldsByName[name[i+1:]]
+			return field, ok
+		}
+		return FieldInfo{}, false
+	}
+
+	var errs []error
+
+	if variables.Where != "" {
+		if err := validateWhere(variables.Where, lookupField); err != nil {
+			errs = append(errs, fmt.Errorf("invalid where clause: %w", err))
+		}
+	}
+
+	for _, outFields := range variables.OutFields {
+		for _, outField := range strings.Split(outFields, ",") {
+			outField = strings.TrimSpace(outField)
+			if outField == "*" {
+				continue
+			}
+			if _, ok := lookupField(outField); !ok {
+				errs = append(errs, fmt.Errorf("unknown out field '%s'", outField))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+const (
+	sqlValueString = "string"
+	sqlValueNumber = "number"
+	sqlValueDate   = "date"
+)
+
+// Words of standardized SQL that are never field names
+var sqlKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "IS": true, "NULL": true,
+	"LIKE": true, "ESCAPE": true, "BETWEEN": true, "TRUE": true, "FALSE": true,
+	"DATE": true, "TIME": true, "TIMESTAMP": true, "INTERVAL": true,
+	"CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"CAST": true, "AS": true, "FROM": true, "SELECT": true, "WHERE": true,
+	"DISTINCT": true, "EXISTS": true, "ALL": true, "ANY": true, "SOME": true,
+	"YEAR": true, "MONTH": true, "DAY": true, "HOUR": true, "MINUTE": true, "SECOND": true,
+	"BOTH": true, "LEADING": true, "TRAILING": true,
+	"INTEGER": true, "SMALLINT": true, "FLOAT": true, "REAL": true, "DOUBLE": true,
+	"PRECISION": true, "DECIMAL": true, "NUMERIC": true, "CHAR": true, "VARCHAR": true,
+	"CHARACTER": true,
+}
+
+// The kind of literal a field can be compared with, empty if the field type
+// isn't checked
+func fieldValueKind(fieldType string) string {
+	switch fieldType {
+	case FieldTypeOID, FieldTypeSmallInt, FieldTypeInt, FieldTypeFloat, FieldTypeDouble:
+		return sqlValueNumber
+	case FieldTypeString:
+		return sqlValueString
+	case FieldTypeDate:
+		return sqlValueDate
+	default:
+		return ""
+	}
+}
+
+func validateWhere(where string, lookupField func(name string) (FieldInfo, bool)) error {
+	tokens, err := tokenizeSQL(where)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	unknown := make(map[string]bool)
+
+	isWord := func(i int, word string) bool {
+		return i < len(tokens) && tokens[i].kind == sqlTokenIdentifier && strings.EqualFold(tokens[i].text, word)
+	}
+	isPunctuation := func(i int, p string) bool {
+		return i < len(tokens) && tokens[i].kind == sqlTokenPunctuation && tokens[i].text == p
+	}
+	isOperator := func(i int, operators ...string) bool {
+		if i < 0 || i >= len(tokens) || tokens[i].kind != sqlTokenOperator {
+			return false
+		}
+		for _, operator := range operators {
+			if tokens[i].text == operator {
+				return true
+			}
+		}
+		return false
+	}
+	isComparison := func(i int) bool {
+		return isOperator(i, "=", "<>", "!=", "<", "<=", ">", ">=")
+	}
+	isArithmetic := func(i int) bool {
+		return isOperator(i, "+", "-", "*", "/", "%", "||")
+	}
+	// Function names are followed by their arguments
+	isField := func(i int) bool {
+		if i >= len(tokens) || isPunctuation(i+1, "(") {
+			return false
+		}
+		switch tokens[i].kind {
+		case sqlTokenQuotedIdentifier:
+			return true
+		case sqlTokenIdentifier:
+			return !sqlKeywords[strings.ToUpper(tokens[i].text)]
+		default:
+			return false
+		}
+	}
+	// Returns the kind of the literal at i and the index of the token after it
+	literal := func(i int) (kind string, next int, ok bool) {
+		switch {
+		case i >= len(tokens):
+			return "", i, false
+		case tokens[i].kind == sqlTokenString:
+			return sqlValueString, i + 1, true
+		case tokens[i].kind == sqlTokenNumber:
+			return sqlValueNumber, i + 1, true
+		case isOperator(i, "+", "-") && i+1 < len(tokens) && tokens[i+1].kind == sqlTokenNumber:
+			return sqlValueNumber, i + 2, true
+		case (isWord(i, "date") || isWord(i, "timestamp") || isWord(i, "time")) &&
+			i+1 < len(tokens) && tokens[i+1].kind == sqlTokenString:
+			return sqlValueDate, i + 2, true
+		default:
+			return "", i, false
+		}
+	}
+	check := func(field FieldInfo, kind string) {
+		fieldKind := fieldValueKind(field.Type)
+		if fieldKind == "" || fieldKind == kind {
+			return
+		}
+		// Dates can also be written as strings
+		if fieldKind == sqlValueDate && kind == sqlValueString {
+			return
+		}
+		errs = append(errs, fmt.Errorf("field '%s' has type '%s' but is compared with a %s", field.Name, field.Type, kind))
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		// Subqueries use the fields of other tables
+		if isPunctuation(i, "(") && isWord(i+1, "select") {
+			depth := 0
+			for ; i < len(tokens); i++ {
+				if isPunctuation(i, "(") {
+					depth++
+				} else if isPunctuation(i, ")") {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			continue
+		}
+
+		if !isField(i) {
+			// A literal compared with a field, like 5 < population
+			if kind, next, ok := literal(i); ok && !isArithmetic(i-1) && isComparison(next) && isField(next+1) && !isArithmetic(next+2) {
+				if field, ok := lookupField(tokens[next+1].text); ok {
+					check(field, kind)
+				}
+			}
+			continue
+		}
+
+		name := tokens[i].text
+		field, ok := lookupField(name)
+		if !ok {
+			if !unknown[strings.ToLower(name)] {
+				unknown[strings.ToLower(name)] = true
+				errs = append(errs, fmt.Errorf("unknown field '%s'", name))
+			}
+			continue
+		}
+
+		j := i + 1
+		if isWord(j, "not") {
+			j++
+		}
+
+		switch {
+		case j == i+1 && isComparison(j):
+			if kind, next, ok := literal(j + 1); ok && !isArithmetic(next) {
+				check(field, kind)
+			}
+		case isWord(j, "in") && isPunctuation(j+1, "("):
+			for k := j + 2; ; {
+				kind, next, ok := literal(k)
+				if !ok {
+					break
+				}
+				check(field, kind)
+				if !isPunctuation(next, ",") {
+					break
+				}
+				k = next + 1
+			}
+		case isWord(j, "between"):
+			if kind, next, ok := literal(j + 1); ok {
+				check(field, kind)
+				if isWord(next, "and") {
+					if kind, _, ok := literal(next + 1); ok {
+						check(field, kind)
+					}
+				}
+			}
+		case isWord(j, "like"):
+			if kind := fieldValueKind(field.Type); kind != "" && kind != sqlValueString {
+				errs = append(errs, fmt.Errorf("field '%s' has type '%s' but is used with LIKE", field.Name, field.Type))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+package featureserver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQuery(t *testing.T) {
+	info := FeatureLayerInfo{
+		Type:         LayerTypeFeatureLayer,
+		GeometryType: GeometryTypePoint,
+		Fields: []FieldInfo{
+			{Name: "OBJECTID", Type: FieldTypeOID},
+			{Name: "name", Type: FieldTypeString},
+			{Name: "population", Type: FieldTypeInt},
+			{Name: "area", Type: FieldTypeDouble},
+			{Name: "founded", Type: FieldTypeDate},
+		},
+	}
+
+	valid := []QueryVariables{
+		{Where: "1=1", OutFields: []string{"*"}},
+		{Where: "objectid IN (1, 2, 3)", OutFields: []string{"name", "POPULATION"}},
+		{Where: "name = 'O''Brien' AND population > -5"},
+		{Where: "UPPER(name) LIKE 'MAIN%' OR area BETWEEN 1.5 AND 2e3"},
+		{Where: `name LIKE '50\%%' ESCAPE '\'`},
+		{Where: "name = N'Zoë' OR name IN (n'a', 'b')"},
+		{Where: "founded > timestamp '2020-01-01 00:00:00' AND founded < '2021-01-01'"},
+		{Where: "founded >= CURRENT_DATE - 7 AND name IS NOT NULL"},
+		{Where: "100 < population AND NOT (name IN ('a', 'b'))"},
+		{Where: "population * 2 = 'x'"},
+		{Where: "objectid IN (SELECT other_id FROM other WHERE other_name = 1)"},
+		{Where: "\"name\" = 'x'", OutFields: []string{"name,area"}},
+	}
+
+	for _, vars := range valid {
+		t.Run(vars.Where, func(t *testing.T) {
+			if err := ValidateQuery(vars, info); err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+		})
+	}
+
+	invalid := []struct {
+		Variables QueryVariables
+		Expected  []string
+	}{
+		{Variables: QueryVariables{Where: "nmae = 'x'"}, Expected: []string{"unknown field 'nmae'"}},
+		{Variables: QueryVariables{Where: "population = '5'"}, Expected: []string{"'population'", "string"}},
+		{Variables: QueryVariables{Where: "name = 5"}, Expected: []string{"'name'", "number"}},
+		{Variables: QueryVariables{Where: "'5' > population"}, Expected: []string{"'population'", "string"}},
+		{Variables: QueryVariables{Where: "population IN (1, '2')"}, Expected: []string{"'population'", "string"}},
+		{Variables: QueryVariables{Where: "area BETWEEN 1 AND 'b'"}, Expected: []string{"'area'", "string"}},
+		{Variables: QueryVariables{Where: "founded = 5"}, Expected: []string{"'founded'", "number"}},
+		{Variables: QueryVariables{Where: "population LIKE '5%'"}, Expected: []string{"'population'", "LIKE"}},
+		{Variables: QueryVariables{Where: "name = 'x"}, Expected: []string{"unterminated string"}},
+		{Variables: QueryVariables{Where: "1=1", OutFields: []string{"name", "populaton"}}, Expected: []string{"unknown out field 'populaton'"}},
+		{Variables: QueryVariables{Where: "nmae = 'x' AND aera > 1", OutFields: []string{"naem"}}, Expected: []string{"'nmae'", "'aera'", "'naem'"}},
+	}
+
+	for _, test := range invalid {
+		t.Run(test.Variables.Where, func(t *testing.T) {
+			err := ValidateQuery(test.Variables, info)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			for _, expected := range test.Expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain '%s', got: %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateFeature(t *testing.T) {
+	z := 1.0
+
+	tests := []struct {
+		name    string
+		feature interface{}
+		info    FeatureLayerInfo
+		valid   bool
+	}{
+		{
+			name:    "Polyline",
+			feature: TypedFeature[struct{}, GeometryPolyline]{Geometry: GeometryPolyline{Paths: [][]Coordinate{{{X: 1, Y: 2}, {X: 3, Y: 4}}}}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolyline},
+			valid:   true,
+		},
+		{
+			name:    "Polyline with z values",
+			feature: TypedFeature[struct{}, GeometryPolyline]{Geometry: GeometryPolyline{HasZ: true, Paths: [][]Coordinate{{{X: 1, Y: 2, Z: &z}}}}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolyline, HasZ: true},
+			valid:   true,
+		},
+		{
+			name:    "Polyline on polygon layer",
+			feature: TypedFeature[struct{}, GeometryPolyline]{},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolygon},
+		},
+		{
+			name:    "Polyline without z values on z layer",
+			feature: TypedFeature[struct{}, GeometryPolyline]{Geometry: GeometryPolyline{Paths: [][]Coordinate{{{X: 1, Y: 2}}}}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolyline, HasZ: true},
+		},
+		{
+			name:    "Polygon",
+			feature: TypedFeature[struct{}, GeometryPolygon]{Geometry: GeometryPolygon{Rings: [][]Coordinate{{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 0}}}}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolygon},
+			valid:   true,
+		},
+		{
+			name:    "Polygon on polyline layer",
+			feature: TypedFeature[struct{}, GeometryPolygon]{},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolyline},
+		},
+		{
+			name:    "Envelope",
+			feature: TypedFeature[struct{}, GeometryEnvelope]{Geometry: GeometryEnvelope{XMin: 1, YMin: 2, XMax: 3, YMax: 4}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypeEnvelope},
+			valid:   true,
+		},
+		{
+			name:    "Envelope on polygon layer",
+			feature: TypedFeature[struct{}, GeometryEnvelope]{},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolygon},
+		},
+		{
+			name:    "Polygon on envelope layer",
+			feature: TypedFeature[struct{}, GeometryPolygon]{},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypeEnvelope},
+		},
+		{
+			name:    "Polygon with z values on 2d layer",
+			feature: TypedFeature[struct{}, GeometryPolygon]{Geometry: GeometryPolygon{HasZ: true, Rings: [][]Coordinate{{{X: 0, Y: 0, Z: &z}}}}},
+			info:    FeatureLayerInfo{GeometryType: GeometryTypePolygon},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateFeature(test.feature, test.info)
+			if test.valid && err != nil {
+				t.Errorf("expected no error, got: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package featureserver
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheAschr/arcgis"
+)
+
+// A where clause expression in standardized SQL. Values are escaped when the
+// expression is written so user input can be used safely.
+//
+//	where, err := And(Eq("status", "open"), Between("created", start, end)).SQL()
+type Expression interface {
+	// Returns the expression as standardized SQL
+	SQL() (string, error)
+	// Returns the names of the fields used in the expression
+	Fields() []string
+}
+
+// Field names may be qualified with a table name, anything else is rejected so
+// a field name can't be used to inject SQL
+var fieldNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+type comparisonExpression struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+func (e comparisonExpression) SQL() (string, error) {
+	if err := checkFieldName(e.field); err != nil {
+		return "", err
+	}
+	if e.value == nil {
+		return "", fmt.Errorf("can't compare '%s' to null, use IsNull instead", e.field)
+	}
+	value, err := sqlLiteral(e.value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for '%s': %w", e.field, err)
+	}
+	return fmt.Sprintf("%s %s %s", e.field, e.operator, value), nil
+}
+
+func (e comparisonExpression) Fields() []string {
+	return []string{e.field}
+}
+
+// field = value
+func Eq(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: "=", value: value}
+}
+
+// field <> value
+func Ne(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: "<>", value: value}
+}
+
+// field < value
+func Lt(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: "<", value: value}
+}
+
+// field <= value
+func Le(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: "<=", value: value}
+}
+
+// field > value
+func Gt(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: ">", value: value}
+}
+
+// field >= value
+func Ge(field string, value interface{}) Expression {
+	return comparisonExpression{field: field, operator: ">=", value: value}
+}
+
+type likeExpression struct {
+	field   string
+	pattern string
+	// If true, the pattern escapes wildcards with a backslash
+	escape bool
+}
+
+func (e likeExpression) SQL() (string, error) {
+	if err := checkFieldName(e.field); err != nil {
+		return "", err
+	}
+	if e.escape {
+		return fmt.Sprintf("%s LIKE %s ESCAPE '\\'", e.field, quoteSQLString(e.pattern)), nil
+	}
+	return fmt.Sprintf("%s LIKE %s", e.field, quoteSQLString(e.pattern)), nil
+}
+
+func (e likeExpression) Fields() []string {
+	return []string{e.field}
+}
+
+// field LIKE pattern. The pattern may use the wildcards % and _, so a % or _
+// in user input matches anything. Use LikeEscaped with EscapeLike for
+// patterns built from user input.
+func Like(field string, pattern string) Expression {
+	return likeExpression{field: field, pattern: pattern}
+}
+
+// field LIKE pattern ESCAPE '\'. Like Like but a backslash in the pattern
+// makes the character after it literal, so the parts of the pattern escaped
+// with EscapeLike only match themselves.
+//
+//	LikeEscaped("name", EscapeLike(input)+"%")
+func LikeEscaped(field string, pattern string) Expression {
+	return likeExpression{field: field, pattern: pattern, escape: true}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Escapes the wildcards % and _ and the escape character \ of a LikeEscaped
+// pattern
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+type inExpression struct {
+	field  string
+	values []interface{}
+}
+
+func (e inExpression) SQL() (string, error) {
+	if err := checkFieldName(e.field); err != nil {
+		return "", err
+	}
+	if len(e.values) == 0 {
+		return "", fmt.Errorf("no values for '%s'", e.field)
+	}
+	values := make([]string, len(e.values))
+	for i, v := range e.values {
+		if v == nil {
+			return "", fmt.Errorf("can't compare '%s' to null, use IsNull instead", e.field)
+		}
+		value, err := sqlLiteral(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for '%s': %w", e.field, err)
+		}
+		values[i] = value
+	}
+	return fmt.Sprintf("%s IN (%s)", e.field, strings.Join(values, ", ")), nil
+}
+
+func (e inExpression) Fields() []string {
+	return []string{e.field}
+}
+
+// field IN (values...)
+func In(field string, values ...interface{}) Expression {
+	return inExpression{field: field, values: values}
+}
+
+type betweenExpression struct {
+	field string
+	low   interface{}
+	high  interface{}
+}
+
+func (e betweenExpression) SQL() (string, error) {
+	if err := checkFieldName(e.field); err != nil {
+		return "", err
+	}
+	if e.low == nil || e.high == nil {
+		return "", fmt.Errorf("can't compare '%s' to null", e.field)
+	}
+	low, err := sqlLiteral(e.low)
+	if err != nil {
+		return "", fmt.Errorf("invalid lower bound for '%s': %w", e.field, err)
+	}
+	high, err := sqlLiteral(e.high)
+	if err != nil {
+		return "", fmt.Errorf("invalid upper bound for '%s': %w", e.field, err)
+	}
+	return fmt.Sprintf("%s BETWEEN %s AND %s", e.field, low, high), nil
+}
+
+func (e betweenExpression) Fields() []string {
+	return []string{e.field}
+}
+
+// field BETWEEN low AND high, both bounds are inclusive
+func Between(field string, low interface{}, high interface{}) Expression {
+	return betweenExpression{field: field, low: low, high: high}
+}
+
+type isNullExpression struct {
+	field string
+}
+
+func (e isNullExpression) SQL() (string, error) {
+	if err := checkFieldName(e.field); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s IS NULL", e.field), nil
+}
+
+func (e isNullExpression) Fields() []string {
+	return []string{e.field}
+}
+
+// field IS NULL
+func IsNull(field string) Expression {
+	return isNullExpression{field: field}
+}
+
+type logicalExpression struct {
+	operator string
+	// Written when there are no expressions
+	empty       string
+	expressions []Expression
+}
+
+func (e logicalExpression) SQL() (string, error) {
+	if len(e.expressions) == 0 {
+		return e.empty, nil
+	}
+	if len(e.expressions) == 1 {
+		return e.expressions[0].SQL()
+	}
+	parts := make([]string, len(e.expressions))
+	for i, expression := range e.expressions {
+		sql, err := expression.SQL()
+		if err != nil {
+			return "", err
+		}
+		parts[i] = "(" + sql + ")"
+	}
+	return strings.Join(parts, " "+e.operator+" "), nil
+}
+
+func (e logicalExpression) Fields() []string {
+	var fields []string
+	for _, expression := range e.expressions {
+		fields = append(fields, expression.Fields()...)
+	}
+	return fields
+}
+
+// All of the expressions must be true, an empty And is always true
+func And(expressions ...Expression) Expression {
+	return logicalExpression{operator: "AND", empty: "1=1", expressions: expressions}
+}
+
+// Any of the expressions must be true, an empty Or is always false
+func Or(expressions ...Expression) Expression {
+	return logicalExpression{operator: "OR", empty: "1=0", expressions: expressions}
+}
+
+type notExpression struct {
+	expression Expression
+}
+
+func (e notExpression) SQL() (string, error) {
+	sql, err := e.expression.SQL()
+	if err != nil {
+		return "", err
+	}
+	return "NOT (" + sql + ")", nil
+}
+
+func (e notExpression) Fields() []string {
+	return e.expression.Fields()
+}
+
+// NOT expression
+func Not(expression Expression) Expression {
+	return notExpression{expression: expression}
+}
+
+// Checks that every field used in the expression is one of the fields. Field
+// names are compared case insensitively like Arcgis does.
+func ValidateExpression(expression Expression, fields []FieldInfo) error {
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[strings.ToLower(field.Name)] = true
+	}
+
+	var errs []error
+	for _, field := range expression.Fields() {
+		if !known[strings.ToLower(field)] {
+			errs = append(errs, fmt.Errorf("unknown field '%s'", field))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkFieldName(field string) error {
+	if !fieldNameRegexp.MatchString(field) {
+		return fmt.Errorf("invalid field name '%s'", field)
+	}
+	return nil
+}
+
+func quoteSQLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Dates are written as timestamps in UTC. Milliseconds are kept so dates read
+// from Arcgis compare equal to the stored values, finer precision is dropped.
+func sqlTimestamp(t time.Time) string {
+	layout := "2006-01-02 15:04:05"
+	if t.Nanosecond()/int(time.Millisecond) != 0 {
+		layout = "2006-01-02 15:04:05.000"
+	}
+	return "timestamp '" + t.UTC().Format(layout) + "'"
+}
+
+// Formats a value as a standardized SQL literal
+func sqlLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case arcgis.Date:
+		if v.Time == nil {
+			return "", fmt.Errorf("date is null")
+		}
+		return sqlTimestamp(*v.Time), nil
+	case *arcgis.Date:
+		if v == nil || v.Time == nil {
+			return "", fmt.Errorf("date is null")
+		}
+		return sqlTimestamp(*v.Time), nil
+	case time.Time:
+		return sqlTimestamp(v), nil
+	case *time.Time:
+		if v == nil {
+			return "", fmt.Errorf("time is null")
+		}
+		return sqlTimestamp(*v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return "", fmt.Errorf("value is null")
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return quoteSQLString(rv.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("value %v is not a finite number", f)
+		}
+		return strconv.FormatFloat(f, 'f', -1, rv.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+type sqlTokenKind int
+
+const (
+	sqlTokenIdentifier sqlTokenKind = iota
+	// A double quoted identifier, never a keyword
+	sqlTokenQuotedIdentifier
+	sqlTokenString
+	sqlTokenNumber
+	sqlTokenOperator
+	sqlTokenPunctuation
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	// Quotes are removed from strings and quoted identifiers
+	text string
+}
+
+// Splits a standardized SQL where clause into tokens
+func tokenizeSQL(s string) ([]sqlToken, error) {
+	var tokens []sqlToken
+
+	isIdentifierStart := func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	isDigit := func(c byte) bool {
+		return c >= '0' && c <= '9'
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || ((c == 'N' || c == 'n') && i+1 < len(s) && s[i+1] == '\''):
+			// National string literals N'...' are strings like any other
+			if c != '\'' {
+				i++
+			}
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(s) {
+					return nil, fmt.Errorf("unterminated string at offset %d", i)
+				}
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						sb.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(s[j])
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenString, text: sb.String()})
+			i = j + 1
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated identifier at offset %d", i)
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenQuotedIdentifier, text: s[i+1 : i+1+end]})
+			i += end + 2
+		case isIdentifierStart(c):
+			j := i + 1
+			for j < len(s) && (isIdentifierStart(s[j]) || isDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenIdentifier, text: s[i:j]})
+			i = j
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			j := i
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && isDigit(s[k]) {
+					for j = k; j < len(s) && isDigit(s[j]); j++ {
+					}
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: sqlTokenNumber, text: s[i:j]})
+			i = j
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, sqlToken{kind: sqlTokenPunctuation, text: string(c)})
+			i++
+		case strings.HasPrefix(s[i:], "<>") || strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">=") ||
+			strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, sqlToken{kind: sqlTokenOperator, text: s[i : i+2]})
+			i += 2
+		case strings.IndexByte("=<>+-*/%", c) >= 0:
+			tokens = append(tokens, sqlToken{kind: sqlTokenOperator, text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at offset %d", c, i)
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,89 @@
+package featureserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheAschr/arcgis"
+)
+
+func TestExpressionSQL(t *testing.T) {
+	date := arcgis.NewDateFromUnixMillis(time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC).UnixMilli())
+
+	tests := []struct {
+		Name       string
+		Expression Expression
+		Expected   string
+	}{
+		{Name: "Eq string", Expression: Eq("name", "O'Brien"), Expected: "name = 'O''Brien'"},
+		{Name: "Eq injection", Expression: Eq("name", "x' OR '1'='1"), Expected: "name = 'x'' OR ''1''=''1'"},
+		{Name: "Ne int", Expression: Ne("count", 5), Expected: "count <> 5"},
+		{Name: "Gt float", Expression: Gt("area", 1.5), Expected: "area > 1.5"},
+		{Name: "Le pointer", Expression: Le("area", arcgis.Nullable(float32(0.1))), Expected: "area <= 0.1"},
+		{Name: "Like", Expression: Like("name", "Main%"), Expected: "name LIKE 'Main%'"},
+		{Name: "Like escaped", Expression: LikeEscaped("name", EscapeLike(`50%_off\'s`)+"%"), Expected: `name LIKE '50\%\_off\\''s%' ESCAPE '\'`},
+		{Name: "In", Expression: In("status", "open", "closed"), Expected: "status IN ('open', 'closed')"},
+		{Name: "Between dates", Expression: Between("created", date, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), Expected: "created BETWEEN timestamp '2023-05-06 07:08:09' AND timestamp '2024-01-01 00:00:00'"},
+		{Name: "Eq date with milliseconds", Expression: Eq("created", arcgis.NewDateFromUnixMillis(1706498064123)), Expected: "created = timestamp '2024-01-29 03:14:24.123'"},
+		{Name: "Lt time with nanoseconds", Expression: Lt("created", time.Date(2024, 1, 1, 0, 0, 0, 5_500_000, time.UTC)), Expected: "created < timestamp '2024-01-01 00:00:00.005'"},
+		{Name: "IsNull", Expression: IsNull("owner"), Expected: "owner IS NULL"},
+		{Name: "And Or Not", Expression: And(Eq("a", 1), Or(Eq("b", 2), Not(IsNull("c")))), Expected: "(a = 1) AND ((b = 2) OR (NOT (c IS NULL)))"},
+		{Name: "Empty And", Expression: And(), Expected: "1=1"},
+		{Name: "Empty Or", Expression: Or(), Expected: "1=0"},
+		{Name: "Qualified field", Expression: Eq("parcels.id", 1), Expected: "parcels.id = 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			sql, err := test.Expression.SQL()
+			if err != nil {
+				t.Fatalf("failed to write expression: %v", err)
+			}
+			if sql != test.Expected {
+				t.Errorf("expected '%s', got: '%s'", test.Expected, sql)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		Name       string
+		Expression Expression
+	}{
+		{Name: "Invalid field name", Expression: Eq("name = 1 OR 1", 1)},
+		{Name: "Null value", Expression: Eq("name", nil)},
+		{Name: "Null date", Expression: Eq("created", arcgis.Date{})},
+		{Name: "Empty In", Expression: In("status")},
+		{Name: "Unsupported value", Expression: Eq("name", []string{"a"})},
+		{Name: "Nested error", Expression: And(Eq("a", 1), Eq("b;", 2))},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.Name, func(t *testing.T) {
+			if sql, err := test.Expression.SQL(); err == nil {
+				t.Errorf("expected error, got: '%s'", sql)
+			}
+		})
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	fields := []FieldInfo{
+		{Name: "OBJECTID", Type: FieldTypeOID},
+		{Name: "name", Type: FieldTypeString},
+	}
+
+	if err := ValidateExpression(And(Eq("objectid", 1), Like("NAME", "a%"), LikeEscaped("name", "b\\%%")), fields); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+
+	err := ValidateExpression(Or(Eq("nmae", "a"), IsNull("owner")), fields)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	for _, field := range []string{"nmae", "owner"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error to mention '%s', got: %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package featureserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	wkbBigEndian    = 0
+	wkbLittleEndian = 1
+
+	// ISO type code offsets for z and m values
+	wkbISOZ = 1000
+	wkbISOM = 2000
+
+	// EWKB type code flags
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// Encodes one of the geometry types as ISO well-known binary in little endian.
+// Z and M values use the 1000, 2000 and 3000 type code offsets. GeometryNone
+// is encoded as an empty geometry collection.
+func MarshalWKB(geometry interface{}) ([]byte, error) {
+	g, err := toOGC(geometry)
+	if err != nil {
+		return nil, err
+	}
+	return appendWKB(nil, g, false, false), nil
+}
+
+// Encodes one of the geometry types as PostGIS extended well-known binary in
+// little endian. The SRID is taken from the spatial reference of the geometry
+// and left out when there is none.
+func MarshalEWKB(geometry interface{}) ([]byte, error) {
+	g, err := toOGC(geometry)
+	if err != nil {
+		return nil, err
+	}
+	return appendWKB(nil, g, true, g.srid != 0), nil
+}
+
+// Decodes ISO or extended well-known binary in to one of the geometry types.
+// An EWKB SRID sets the spatial reference of the geometry.
+func UnmarshalWKB(b []byte) (interface{}, error) {
+	r := wkbReader{b: b}
+	g, err := r.geometry()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wkb: %w", err)
+	}
+	if len(r.b) != 0 {
+		return nil, fmt.Errorf("failed to decode wkb: %d trailing bytes", len(r.b))
+	}
+	return fromOGC(g)
+}
+
+func appendWKB(b []byte, g ogcGeometry, extended bool, withSRID bool) []byte {
+	typeCode := uint32(g.kind)
+	if extended {
+		if g.hasZ {
+			typeCode |= ewkbZ
+		}
+		if g.hasM {
+			typeCode |= ewkbM
+		}
+		if withSRID {
+			typeCode |= ewkbSRID
+		}
+	} else {
+		if g.hasZ {
+			typeCode += wkbISOZ
+		}
+		if g.hasM {
+			typeCode += wkbISOM
+		}
+	}
+
+	b = append(b, wkbLittleEndian)
+	b = binary.LittleEndian.AppendUint32(b, typeCode)
+	if withSRID {
+		b = binary.LittleEndian.AppendUint32(b, uint32(g.srid))
+	}
+
+	appendCoordinate := func(b []byte, c Coordinate) []byte {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(c.X))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(c.Y))
+		if g.hasZ {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(valueOrZero(c.Z)))
+		}
+		if g.hasM {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(valueOrZero(c.M)))
+		}
+		return b
+	}
+
+	appendCoordinates := func(b []byte, cs []Coordinate) []byte {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(cs)))
+		for _, c := range cs {
+			b = appendCoordinate(b, c)
+		}
+		return b
+	}
+
+	appendLines := func(b []byte, lines [][]Coordinate) []byte {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(lines)))
+		for _, line := range lines {
+			b = appendCoordinates(b, line)
+		}
+		return b
+	}
+
+	// Parts of multi geometries are complete geometries of their own, only the
+	// outer geometry carries the SRID
+	part := func(kind int) ogcGeometry {
+		return ogcGeometry{kind: kind, hasZ: g.hasZ, hasM: g.hasM}
+	}
+
+	switch g.kind {
+	case ogcPoint:
+		if len(g.points) == 0 {
+			// An empty point is encoded with NaN coordinates
+			nan := math.NaN()
+			return appendCoordinate(b, Coordinate{X: nan, Y: nan, Z: &nan, M: &nan})
+		}
+		b = appendCoordinate(b, g.points[0])
+	case ogcLineString:
+		b = appendCoordinates(b, g.points)
+	case ogcPolygon:
+		b = appendLines(b, g.lines)
+	case ogcMultiPoint:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(g.points)))
+		for _, c := range g.points {
+			p := part(ogcPoint)
+			p.points = []Coordinate{c}
+			b = appendWKB(b, p, extended, false)
+		}
+	case ogcMultiLineString:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(g.lines)))
+		for _, line := range g.lines {
+			p := part(ogcLineString)
+			p.points = line
+			b = appendWKB(b, p, extended, false)
+		}
+	case ogcMultiPolygon:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(g.polygons)))
+		for _, polygon := range g.polygons {
+			p := part(ogcPolygon)
+			p.lines = polygon
+			b = appendWKB(b, p, extended, false)
+		}
+	case ogcGeometryCollection:
+		b = binary.LittleEndian.AppendUint32(b, 0)
+	}
+
+	return b
+}
+
+type wkbReader struct {
+	b     []byte
+	order binary.ByteOrder
+	hasZ  bool
+	hasM  bool
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.b) < 4 {
+		return 0, fmt.Errorf("unexpected end of wkb")
+	}
+	v := r.order.Uint32(r.b)
+	r.b = r.b[4:]
+	return v, nil
+}
+
+func (r *wkbReader) float64() (float64, error) {
+	if len(r.b) < 8 {
+		return 0, fmt.Errorf("unexpected end of wkb")
+	}
+	v := math.Float64frombits(r.order.Uint64(r.b))
+	r.b = r.b[8:]
+	return v, nil
+}
+
+func (r *wkbReader) coordinate() (c Coordinate, err error) {
+	dimensions := 2
+	if r.hasZ {
+		dimensions++
+	}
+	if r.hasM {
+		dimensions++
+	}
+	values := make([]*float64, dimensions)
+	for i := range values {
+		v, err := r.float64()
+		if err != nil {
+			return c, err
+		}
+		values[i] = &v
+	}
+	return newCoordinate(values, r.hasZ, r.hasM)
+}
+
+func (r *wkbReader) coordinates() ([]Coordinate, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// Each coordinate takes at least 16 bytes, checking this up front keeps a
+	// corrupt count from allocating a huge slice
+	if uint64(n)*16 > uint64(len(r.b)) {
+		return nil, fmt.Errorf("unexpected end of wkb")
+	}
+	cs := make([]Coordinate, n)
+	for i := range cs {
+		if cs[i], err = r.coordinate(); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+func (r *wkbReader) lines() ([][]Coordinate, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n)*4 > uint64(len(r.b)) {
+		return nil, fmt.Errorf("unexpected end of wkb")
+	}
+	lines := make([][]Coordinate, n)
+	for i := range lines {
+		if lines[i], err = r.coordinates(); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// Reads the parts of a multi geometry, each being a geometry of the given kind
+func (r *wkbReader) parts(kind int) ([]ogcGeometry, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	// Each part takes at least 5 bytes for its byte order and type
+	if uint64(n)*5 > uint64(len(r.b)) {
+		return nil, fmt.Errorf("unexpected end of wkb")
+	}
+	parts := make([]ogcGeometry, n)
+	for i := range parts {
+		if parts[i], err = r.geometry(); err != nil {
+			return nil, err
+		}
+		if parts[i].kind != kind {
+			return nil, fmt.Errorf("expected %s part but got %s", ogcNames[kind], ogcNames[parts[i].kind])
+		}
+	}
+	return parts, nil
+}
+
+func (r *wkbReader) geometry() (g ogcGeometry, err error) {
+	if len(r.b) < 1 {
+		return g, fmt.Errorf("unexpected end of wkb")
+	}
+	switch r.b[0] {
+	case wkbBigEndian:
+		r.order = binary.BigEndian
+	case wkbLittleEndian:
+		r.order = binary.LittleEndian
+	default:
+		return g, fmt.Errorf("invalid byte order: %d", r.b[0])
+	}
+	r.b = r.b[1:]
+
+	typeCode, err := r.uint32()
+	if err != nil {
+		return g, err
+	}
+
+	g.hasZ = typeCode&ewkbZ != 0
+	g.hasM = typeCode&ewkbM != 0
+	if typeCode&ewkbSRID != 0 {
+		srid, err := r.uint32()
+		if err != nil {
+			return g, err
+		}
+		g.srid = int(srid)
+	}
+
+	typeCode &^= ewkbZ | ewkbM | ewkbSRID
+	switch typeCode / 1000 {
+	case 1:
+		g.hasZ = true
+	case 2:
+		g.hasM = true
+	case 3:
+		g.hasZ, g.hasM = true, true
+	}
+	g.kind = int(typeCode % 1000)
+
+	r.hasZ, r.hasM = g.hasZ, g.hasM
+
+	switch g.kind {
+	case ogcPoint:
+		c, err := r.coordinate()
+		if err != nil {
+			return g, err
+		}
+		if !math.IsNaN(c.X) || !math.IsNaN(c.Y) {
+			g.points = []Coordinate{c}
+		}
+	case ogcLineString:
+		g.points, err = r.coordinates()
+	case ogcPolygon:
+		g.lines, err = r.lines()
+	case ogcMultiPoint:
+		var parts []ogcGeometry
+		parts, err = r.parts(ogcPoint)
+		for _, part := range parts {
+			g.points = append(g.points, part.points...)
+		}
+	case ogcMultiLineString:
+		var parts []ogcGeometry
+		parts, err = r.parts(ogcLineString)
+		for _, part := range parts {
+			g.lines = append(g.lines, part.points)
+		}
+	case ogcMultiPolygon:
+		var parts []ogcGeometry
+		parts, err = r.parts(ogcPolygon)
+		for _, part := range parts {
+			g.polygons = append(g.polygons, part.lines)
+		}
+	case ogcGeometryCollection:
+		var n uint32
+		if n, err = r.uint32(); err == nil && n != 0 {
+			err = fmt.Errorf("unhandled non empty geometry collection")
+		}
+	default:
+		err = fmt.Errorf("unhandled wkb geometry type: %d", typeCode)
+	}
+
+	// Parts may have changed the dimensions of the reader
+	r.hasZ, r.hasM = g.hasZ, g.hasM
+
+	return g, err
+}
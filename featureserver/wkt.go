@@ -0,0 +1,373 @@
+package featureserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Encodes one of the geometry types as well-known text. Z and M values are
+// written with the ISO "Z", "M" and "ZM" dimension tags. GeometryNone is
+// written as an empty geometry collection.
+func MarshalWKT(geometry interface{}) (string, error) {
+	g, err := toOGC(geometry)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ogcNames[g.kind])
+
+	switch {
+	case g.hasZ && g.hasM:
+		sb.WriteString(" ZM")
+	case g.hasZ:
+		sb.WriteString(" Z")
+	case g.hasM:
+		sb.WriteString(" M")
+	}
+
+	writeCoordinate := func(c Coordinate) {
+		sb.WriteString(formatWKTNumber(c.X))
+		sb.WriteByte(' ')
+		sb.WriteString(formatWKTNumber(c.Y))
+		if g.hasZ {
+			sb.WriteByte(' ')
+			sb.WriteString(formatWKTNumber(valueOrZero(c.Z)))
+		}
+		if g.hasM {
+			sb.WriteByte(' ')
+			sb.WriteString(formatWKTNumber(valueOrZero(c.M)))
+		}
+	}
+
+	writeCoordinates := func(cs []Coordinate) {
+		sb.WriteByte('(')
+		for i, c := range cs {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeCoordinate(c)
+		}
+		sb.WriteByte(')')
+	}
+
+	writeLines := func(lines [][]Coordinate) {
+		sb.WriteByte('(')
+		for i, line := range lines {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeCoordinates(line)
+		}
+		sb.WriteByte(')')
+	}
+
+	empty := false
+	switch g.kind {
+	case ogcPoint, ogcLineString:
+		empty = len(g.points) == 0
+	case ogcMultiPoint:
+		empty = len(g.points) == 0
+	case ogcPolygon, ogcMultiLineString:
+		empty = len(g.lines) == 0
+	case ogcMultiPolygon:
+		empty = len(g.polygons) == 0
+	case ogcGeometryCollection:
+		empty = true
+	}
+
+	if empty {
+		sb.WriteString(" EMPTY")
+		return sb.String(), nil
+	}
+
+	sb.WriteByte(' ')
+
+	switch g.kind {
+	case ogcPoint, ogcLineString:
+		writeCoordinates(g.points)
+	case ogcMultiPoint:
+		sb.WriteByte('(')
+		for i, c := range g.points {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeCoordinates([]Coordinate{c})
+		}
+		sb.WriteByte(')')
+	case ogcPolygon, ogcMultiLineString:
+		writeLines(g.lines)
+	case ogcMultiPolygon:
+		sb.WriteByte('(')
+		for i, polygon := range g.polygons {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			writeLines(polygon)
+		}
+		sb.WriteByte(')')
+	}
+
+	return sb.String(), nil
+}
+
+// Decodes well-known text in to one of the geometry types. Extended WKT with a
+// "SRID=<wkid>;" prefix sets the spatial reference of the geometry. Without a
+// dimension tag, three value coordinates are read as x, y and z.
+func UnmarshalWKT(wkt string) (interface{}, error) {
+	p := wktParser{s: wkt}
+
+	var srid int
+	p.skipSpace()
+	if len(p.s) >= 5 && strings.EqualFold(p.s[:5], "SRID=") {
+		end := strings.IndexByte(p.s, ';')
+		if end == -1 {
+			return nil, fmt.Errorf("missing ';' after srid")
+		}
+		var err error
+		if srid, err = strconv.Atoi(strings.TrimSpace(p.s[5:end])); err != nil {
+			return nil, fmt.Errorf("invalid srid: %w", err)
+		}
+		p.s = p.s[end+1:]
+	}
+
+	g, err := p.geometry()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wkt: %w", err)
+	}
+
+	p.skipSpace()
+	if p.s != "" {
+		return nil, fmt.Errorf("failed to parse wkt: unexpected '%s'", p.s)
+	}
+
+	g.srid = srid
+
+	return fromOGC(g)
+}
+
+type wktParser struct {
+	s string
+	// Number of values of each coordinate, zero until known
+	dimensions int
+	hasZ       bool
+	hasM       bool
+}
+
+func (p *wktParser) skipSpace() {
+	p.s = strings.TrimLeftFunc(p.s, unicode.IsSpace)
+}
+
+func (p *wktParser) word() string {
+	p.skipSpace()
+	end := strings.IndexFunc(p.s, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end == -1 {
+		end = len(p.s)
+	}
+	w := strings.ToUpper(p.s[:end])
+	p.s = p.s[end:]
+	return w
+}
+
+func (p *wktParser) peek(b byte) bool {
+	p.skipSpace()
+	return len(p.s) > 0 && p.s[0] == b
+}
+
+func (p *wktParser) expect(b byte) error {
+	if !p.peek(b) {
+		if p.s == "" {
+			return fmt.Errorf("expected '%c' but got end of text", b)
+		}
+		return fmt.Errorf("expected '%c' but got '%c'", b, p.s[0])
+	}
+	p.s = p.s[1:]
+	return nil
+}
+
+func (p *wktParser) geometry() (g ogcGeometry, err error) {
+	name := p.word()
+	for kind, n := range ogcNames {
+		if n == name {
+			g.kind = kind
+		}
+	}
+	if g.kind == 0 {
+		return g, fmt.Errorf("unhandled geometry type '%s'", name)
+	}
+
+	// Dimension tag or EMPTY
+	tag := p.word()
+	switch tag {
+	case "Z":
+		p.hasZ, p.dimensions = true, 3
+	case "M":
+		p.hasM, p.dimensions = true, 3
+	case "ZM":
+		p.hasZ, p.hasM, p.dimensions = true, true, 4
+	case "":
+	case "EMPTY":
+	default:
+		return g, fmt.Errorf("unexpected '%s'", tag)
+	}
+	if tag != "EMPTY" && tag != "" {
+		tag = p.word()
+	}
+	if tag == "EMPTY" {
+		g.hasZ, g.hasM = p.hasZ, p.hasM
+		return g, nil
+	}
+	if tag != "" {
+		return g, fmt.Errorf("unexpected '%s'", tag)
+	}
+
+	switch g.kind {
+	case ogcPoint, ogcLineString:
+		g.points, err = p.coordinates()
+	case ogcMultiPoint:
+		g.points, err = p.multiPoint()
+	case ogcPolygon, ogcMultiLineString:
+		g.lines, err = p.lines()
+	case ogcMultiPolygon:
+		if err = p.expect('('); err != nil {
+			return g, err
+		}
+		for {
+			var polygon [][]Coordinate
+			if polygon, err = p.lines(); err != nil {
+				return g, err
+			}
+			g.polygons = append(g.polygons, polygon)
+			if !p.peek(',') {
+				break
+			}
+			p.s = p.s[1:]
+		}
+		err = p.expect(')')
+	default:
+		return g, fmt.Errorf("unhandled geometry type '%s'", name)
+	}
+
+	g.hasZ, g.hasM = p.hasZ, p.hasM
+
+	return g, err
+}
+
+func (p *wktParser) coordinate() (c Coordinate, err error) {
+	var values []float64
+	for {
+		p.skipSpace()
+		end := strings.IndexFunc(p.s, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ',' || r == ')'
+		})
+		if end == -1 {
+			end = len(p.s)
+		}
+		if end == 0 {
+			break
+		}
+		v, err := strconv.ParseFloat(p.s[:end], 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid number '%s'", p.s[:end])
+		}
+		values = append(values, v)
+		p.s = p.s[end:]
+	}
+
+	if p.dimensions == 0 {
+		if len(values) < 2 || len(values) > 4 {
+			return c, fmt.Errorf("coordinate must have 2 to 4 values but has %d", len(values))
+		}
+		p.dimensions = len(values)
+		p.hasZ = len(values) > 2
+		p.hasM = len(values) > 3
+	}
+	if len(values) != p.dimensions {
+		return c, fmt.Errorf("expected coordinate with %d values but has %d", p.dimensions, len(values))
+	}
+
+	pointers := make([]*float64, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	return newCoordinate(pointers, p.hasZ, p.hasM)
+}
+
+func (p *wktParser) coordinates() (cs []Coordinate, err error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for {
+		c, err := p.coordinate()
+		if err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+		if !p.peek(',') {
+			break
+		}
+		p.s = p.s[1:]
+	}
+	return cs, p.expect(')')
+}
+
+// Multipoints can be written with or without parentheses around each point
+func (p *wktParser) multiPoint() (cs []Coordinate, err error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for {
+		var c Coordinate
+		if p.peek('(') {
+			points, err := p.coordinates()
+			if err != nil {
+				return nil, err
+			}
+			if len(points) != 1 {
+				return nil, fmt.Errorf("expected 1 coordinate in multipoint but got %d", len(points))
+			}
+			c = points[0]
+		} else if c, err = p.coordinate(); err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+		if !p.peek(',') {
+			break
+		}
+		p.s = p.s[1:]
+	}
+	return cs, p.expect(')')
+}
+
+func (p *wktParser) lines() (lines [][]Coordinate, err error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := p.coordinates()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+		if !p.peek(',') {
+			break
+		}
+		p.s = p.s[1:]
+	}
+	return lines, p.expect(')')
+}
+
+func formatWKTNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
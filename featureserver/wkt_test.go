@@ -0,0 +1,148 @@
+package featureserver
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestWKT(t *testing.T) {
+	z, m := 3.0, 4.0
+
+	exterior := []Coordinate{{X: 0, Y: 0}, {X: 0, Y: 10}, {X: 10, Y: 10}, {X: 10, Y: 0}, {X: 0, Y: 0}}
+	hole := []Coordinate{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 4}, {X: 2, Y: 4}, {X: 2, Y: 2}}
+	island := []Coordinate{{X: 20, Y: 20}, {X: 20, Y: 30}, {X: 30, Y: 30}, {X: 30, Y: 20}, {X: 20, Y: 20}}
+
+	type GeometryTest struct {
+		Geometry interface{}
+		WKT      string
+	}
+
+	geometryTests := []GeometryTest{
+		{
+			Geometry: GeometryPoint{X: 1, Y: 2},
+			WKT:      "POINT (1 2)",
+		},
+		{
+			Geometry: GeometryPoint{X: 1, Y: 2, Z: &z, M: &m},
+			WKT:      "POINT ZM (1 2 3 4)",
+		},
+		{
+			Geometry: GeometryMultiPoint{HasM: true, Points: []Coordinate{{X: 1, Y: 2, M: &m}, {X: 5, Y: 6, M: &m}}},
+			WKT:      "MULTIPOINT M ((1 2 4), (5 6 4))",
+		},
+		{
+			Geometry: GeometryPolyline{Paths: [][]Coordinate{{{X: 1, Y: 2}, {X: 3, Y: 4}}}},
+			WKT:      "LINESTRING (1 2, 3 4)",
+		},
+		{
+			Geometry: GeometryPolyline{Paths: [][]Coordinate{{{X: 1, Y: 2}, {X: 3, Y: 4}}, {{X: 5, Y: 6}, {X: 7, Y: 8}}}},
+			WKT:      "MULTILINESTRING ((1 2, 3 4), (5 6, 7 8))",
+		},
+		{
+			Geometry: GeometryPolygon{Rings: [][]Coordinate{exterior, hole}},
+			WKT:      "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 4 2, 2 2))",
+		},
+		{
+			Geometry: GeometryPolygon{Rings: [][]Coordinate{exterior, hole, island}},
+			WKT:      "MULTIPOLYGON (((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 2 4, 4 4, 4 2, 2 2)), ((20 20, 30 20, 30 30, 20 30, 20 20)))",
+		},
+		{
+			Geometry: GeometryPolyline{},
+			WKT:      "LINESTRING EMPTY",
+		},
+		{
+			Geometry: GeometryPolygon{},
+			WKT:      "POLYGON EMPTY",
+		},
+		{
+			Geometry: GeometryNone{},
+			WKT:      "GEOMETRYCOLLECTION EMPTY",
+		},
+	}
+
+	for _, geometryTest := range geometryTests {
+		wkt, err := MarshalWKT(geometryTest.Geometry)
+		if err != nil {
+			t.Fatalf("failed to marshal %T: %v", geometryTest.Geometry, err)
+		}
+		if wkt != geometryTest.WKT {
+			t.Errorf("expected '%s', got: '%s'", geometryTest.WKT, wkt)
+		}
+
+		geometry, err := UnmarshalWKT(wkt)
+		if err != nil {
+			t.Fatalf("failed to unmarshal '%s': %v", wkt, err)
+		}
+		roundTrip, err := MarshalWKT(geometry)
+		if err != nil {
+			t.Fatalf("failed to marshal %T: %v", geometry, err)
+		}
+		if roundTrip != wkt {
+			t.Errorf("expected '%s' to round trip, got: '%s'", wkt, roundTrip)
+		}
+
+		wkb, err := MarshalWKB(geometryTest.Geometry)
+		if err != nil {
+			t.Fatalf("failed to marshal wkb %T: %v", geometryTest.Geometry, err)
+		}
+		geometry, err = UnmarshalWKB(wkb)
+		if err != nil {
+			t.Fatalf("failed to unmarshal wkb of '%s': %v", wkt, err)
+		}
+		roundTrip, err = MarshalWKT(geometry)
+		if err != nil {
+			t.Fatalf("failed to marshal %T: %v", geometry, err)
+		}
+		if roundTrip != wkt {
+			t.Errorf("expected wkb of '%s' to round trip, got: '%s'", wkt, roundTrip)
+		}
+	}
+
+	t.Run("Extended", func(t *testing.T) {
+		geometry, err := UnmarshalWKT("SRID=2913;MULTIPOINT(1 2, 3 4)")
+		if err != nil {
+			t.Fatalf("failed to unmarshal ewkt: %v", err)
+		}
+
+		multiPoint, ok := geometry.(GeometryMultiPoint)
+		if !ok || len(multiPoint.Points) != 2 {
+			t.Fatalf("expected multipoint with 2 points, got: %+v", geometry)
+		}
+		if multiPoint.SpatialReference == nil || multiPoint.SpatialReference.WKID != 2913 {
+			t.Errorf("expected spatial reference 2913, got: %v", multiPoint.SpatialReference)
+		}
+
+		ewkb, err := MarshalEWKB(multiPoint)
+		if err != nil {
+			t.Fatalf("failed to marshal ewkb: %v", err)
+		}
+		geometry, err = UnmarshalWKB(ewkb)
+		if err != nil {
+			t.Fatalf("failed to unmarshal ewkb: %v", err)
+		}
+		multiPoint, ok = geometry.(GeometryMultiPoint)
+		if !ok || multiPoint.SpatialReference == nil || multiPoint.SpatialReference.WKID != 2913 {
+			t.Errorf("expected multipoint with spatial reference 2913, got: %+v", geometry)
+		}
+	})
+
+	t.Run("PostGIS ewkb", func(t *testing.T) {
+		ewkb, err := MarshalEWKB(GeometryPoint{X: 1, Y: 2, SpatialReference: &SpatialReference{WKID: 4326}})
+		if err != nil {
+			t.Fatalf("failed to marshal ewkb: %v", err)
+		}
+		expect := "0101000020E6100000000000000000F03F0000000000000040"
+		if got := strings.ToUpper(hex.EncodeToString(ewkb)); got != expect {
+			t.Errorf("expected %s, got: %s", expect, got)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, wkt := range []string{"POINT (1)", "LINESTRING (1 2, 3 4 5)", "CIRCLE (1 2)", "POINT (1 2", "POINT (1 2) x"} {
+			if _, err := UnmarshalWKT(wkt); err == nil {
+				t.Errorf("expected error for '%s', got none", wkt)
+			}
+		}
+	})
+}